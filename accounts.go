@@ -0,0 +1,391 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account is a registered player. Anyone can claim a name nobody has
+// registered; the key they're given proves it's them from then on.
+type Account struct {
+	Name    string `json:"name"`
+	KeyHash string `json:"keyHash"` // SHA-256 of the key - the key itself is never stored
+	Rating  int    `json:"rating"`
+	Wins    int    `json:"wins"`
+	Losses  int    `json:"losses"`
+	Draws   int    `json:"draws"`
+	Created int64  `json:"created"` // Unix ms
+}
+
+// AccountInfo is an account as players see it
+type AccountInfo struct {
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+	Draws  int    `json:"draws"`
+	Key    string `json:"key,omitempty"` // Only sent once, when the account is created
+}
+
+// RatedGame is the result of a finished game between two accounts
+type RatedGame struct {
+	GameID  string `json:"gameId"`
+	Time    int64  `json:"time"` // Unix ms
+	X       string `json:"x"`    // Account playing X
+	O       string `json:"o"`    // Account playing O
+	Winner  string `json:"winner"`
+	Result  string `json:"result"`
+	RatingX int    `json:"ratingX"` // After the game
+	RatingO int    `json:"ratingO"`
+	Change  int    `json:"change"` // Points X gained (and O lost)
+}
+
+// Elo settings
+const (
+	InitialRating = 1200
+	RatingK       = 32 // Most points a single game can move a rating
+)
+
+// MaxNameLength is the longest display or account name
+const MaxNameLength = 20
+
+// AccountKeyBytes is how many random bytes go into an account key
+const AccountKeyBytes = 16
+
+// Errors returned when signing in
+var (
+	ErrAccountName = fmt.Errorf("Names must be 1-%d characters", MaxNameLength)
+	ErrAccountKey  = errors.New("Wrong key for that name")
+	ErrNameTaken   = errors.New("That name is registered - sign in to use it")
+	ErrBotName     = errors.New("Bots play under the name their token was issued for")
+)
+
+// Accounts holds every account and rated game, saved to a file after each
+// change if it has a path. Safe for use from any goroutine.
+type Accounts struct {
+	mu    sync.Mutex
+	path  string              // "" = in memory only
+	names map[string]*Account // By lower-cased name
+	games []RatedGame
+}
+
+// accounts is where players sign in (in memory unless STATE_DIR is set)
+var accounts = newAccounts()
+
+func newAccounts() *Accounts {
+	return &Accounts{names: make(map[string]*Account)}
+}
+
+// accountsFile is how Accounts are saved
+type accountsFile struct {
+	Accounts []*Account  `json:"accounts"`
+	Games    []RatedGame `json:"games"`
+}
+
+// loadAccounts reads the accounts saved at path, starting afresh if there
+// aren't any yet
+func loadAccounts(path string) (*Accounts, error) {
+	a := newAccounts()
+	a.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file accountsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for _, account := range file.Accounts {
+		a.names[strings.ToLower(account.Name)] = account
+	}
+	a.games = file.Games
+	return a, nil
+}
+
+// save writes everything to a temp file and renames it over the old one.
+// Called with mu held.
+func (a *Accounts) save() {
+	if a.path == "" {
+		return
+	}
+	file := accountsFile{Accounts: make([]*Account, 0, len(a.names)), Games: a.games}
+	for _, account := range a.names {
+		file.Accounts = append(file.Accounts, account)
+	}
+	sort.Slice(file.Accounts, func(i, j int) bool { return file.Accounts[i].Created < file.Accounts[j].Created })
+
+	data, err := json.Marshal(file)
+	if err == nil {
+		tmp := a.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, a.path)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error saving accounts:", err)
+	}
+}
+
+// SignIn checks key against the account registered as name. If nobody has
+// registered the name yet, it's registered now and the new key is returned
+// in the info (any key passed in is ignored).
+func (a *Accounts) SignIn(name, key string) (AccountInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxNameLength {
+		return AccountInfo{}, ErrAccountName
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if account, ok := a.names[strings.ToLower(name)]; ok {
+		if key == "" {
+			return AccountInfo{}, ErrNameTaken
+		}
+		if subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(account.KeyHash)) != 1 {
+			return AccountInfo{}, ErrAccountKey
+		}
+		return account.info(), nil
+	}
+
+	key = randomID(AccountKeyBytes)
+	account := &Account{Name: name, KeyHash: hashKey(key), Rating: InitialRating, Created: time.Now().UnixMilli()}
+	a.names[strings.ToLower(name)] = account
+	a.save()
+
+	info := account.info()
+	info.Key = key
+	return info, nil
+}
+
+// Lookup returns the account registered as name, if there is one
+func (a *Accounts) Lookup(name string) (AccountInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	account, ok := a.names[strings.ToLower(name)]
+	if !ok {
+		return AccountInfo{}, false
+	}
+	return account.info(), true
+}
+
+// Record rates a finished game and adds it to both players' records,
+// returning it with the new ratings filled in
+func (a *Accounts) Record(game RatedGame) (RatedGame, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	x, o := a.names[strings.ToLower(game.X)], a.names[strings.ToLower(game.O)]
+	if x == nil || o == nil {
+		return game, errors.New("unknown account")
+	}
+
+	score := 0.5
+	switch game.Winner {
+	case "X":
+		score = 1
+		x.Wins++
+		o.Losses++
+	case "O":
+		score = 0
+		x.Losses++
+		o.Wins++
+	default:
+		x.Draws++
+		o.Draws++
+	}
+	game.Change = eloChange(x.Rating, o.Rating, score)
+	x.Rating += game.Change
+	o.Rating -= game.Change
+	game.RatingX, game.RatingO = x.Rating, o.Rating
+	game.Time = time.Now().UnixMilli()
+
+	a.games = append(a.games, game)
+	a.save()
+	return game, nil
+}
+
+// Leaderboard lists up to n accounts that have played, highest rated first
+func (a *Accounts) Leaderboard(n int) []AccountInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := []AccountInfo{}
+	for _, account := range a.names {
+		if info := account.info(); info.Games > 0 {
+			list = append(list, info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Rating != list[j].Rating {
+			return list[i].Rating > list[j].Rating
+		}
+		if list[i].Games != list[j].Games {
+			return list[i].Games > list[j].Games
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Games returns the rated games an account played, most recent first
+func (a *Accounts) Games(name string, n int) []RatedGame {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	games := []RatedGame{}
+	for i := len(a.games) - 1; i >= 0 && len(games) < n; i-- {
+		if strings.EqualFold(a.games[i].X, name) || strings.EqualFold(a.games[i].O, name) {
+			games = append(games, a.games[i])
+		}
+	}
+	return games
+}
+
+func (account *Account) info() AccountInfo {
+	return AccountInfo{
+		Name:   account.Name,
+		Rating: account.Rating,
+		Games:  account.Wins + account.Losses + account.Draws,
+		Wins:   account.Wins,
+		Losses: account.Losses,
+		Draws:  account.Draws,
+	}
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// eloChange is how many points X gains (and O loses) for scoring score
+// against O: 1 for a win, 0.5 for a draw, 0 for a loss
+func eloChange(ratingX, ratingO int, score float64) int {
+	expected := 1 / (1 + math.Pow(10, float64(ratingO-ratingX)/400))
+	return int(math.Round(RatingK * (score - expected)))
+}
+
+// signIn makes a client the account's player and tells them so
+func signIn(client *Client, info AccountInfo) {
+	client.Name = info.Name
+	client.account = info.Name
+	sendJSON(client, ServerMessage{Type: "account", Account: &info})
+}
+
+// handleSignIn signs in a client who's in a room. Seated players can't
+// switch accounts partway through a game.
+func (r *Room) handleSignIn(client *Client, name, key string) {
+	if client.api || client.bot != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: ErrBotName.Error()})
+		return
+	}
+	seated := r.ownsSeat(client)
+	if seated && r.gameInProgress() && !strings.EqualFold(r.game.Accounts[client.Role], name) {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Finish the game before signing in"})
+		return
+	}
+
+	info, err := accounts.SignIn(name, key)
+	if err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	signIn(client, info)
+	if seated {
+		r.seatFor(client.Role).Name = info.Name
+		r.setAccount(client.Role, info.Name)
+	}
+
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: fmt.Sprintf("%s signed in as %s (%d)", client.Role, info.Name, info.Rating),
+	})
+}
+
+// ownsSeat reports whether a client is sitting in (rather than watching) the game
+func (r *Room) ownsSeat(client *Client) bool {
+	seat := r.seatFor(client.Role)
+	return seat != nil && seat.Client == client
+}
+
+// gameInProgress reports whether the current game has started and isn't decided
+func (r *Room) gameInProgress() bool {
+	return r.game.Turns > 0 && r.game.Winner == ""
+}
+
+// setAccount records who's signed in to a seat for the current game
+func (r *Room) setAccount(mark, account string) {
+	if r.game.Accounts == nil {
+		r.game.Accounts = make(map[string]string)
+	}
+	if account == "" {
+		delete(r.game.Accounts, mark)
+		return
+	}
+	r.game.Accounts[mark] = account
+}
+
+// rateGame updates both players' ratings once a game between two accounts
+// is decided. Abandoned games count - whoever walked out loses.
+func (r *Room) rateGame() {
+	x, o := r.game.Accounts["X"], r.game.Accounts["O"]
+	if x == "" || o == "" || strings.EqualFold(x, o) || r.game.Turns == 0 {
+		return
+	}
+
+	rated, err := accounts.Record(RatedGame{GameID: r.game.ID, X: x, O: o, Winner: r.game.Winner, Result: r.game.Result})
+	if err != nil {
+		fmt.Println("Error rating game:", err)
+		return
+	}
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: fmt.Sprintf("Ratings: %s %d (%+d), %s %d (%+d)", x, rated.RatingX, rated.Change, o, rated.RatingO, -rated.Change),
+	})
+}
+
+// MaxLeaderboard is how many players /leaderboard lists
+const MaxLeaderboard = 100
+
+// MaxPlayerGames is how many recent games /leaderboard?player=<name> lists
+const MaxPlayerGames = 50
+
+// handleLeaderboard serves GET /leaderboard (top rated players) and
+// GET /leaderboard?player=<name> (one player's record and recent games)
+func handleLeaderboard(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := req.URL.Query().Get("player")
+	if name == "" {
+		json.NewEncoder(w).Encode(accounts.Leaderboard(MaxLeaderboard))
+		return
+	}
+
+	info, ok := accounts.Lookup(name)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Player not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"player": info, "games": accounts.Games(info.Name, MaxPlayerGames)})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestEloChange(t *testing.T) {
+	cases := []struct {
+		x, o  int
+		score float64
+		want  int
+	}{
+		{1200, 1200, 1, 16},
+		{1200, 1200, 0.5, 0},
+		{1200, 1200, 0, -16},
+		{1600, 1200, 1, 3},  // Favourite wins - barely moves
+		{1200, 1600, 1, 29}, // Upset
+		{1600, 1200, 0.5, -13},
+	}
+	for _, c := range cases {
+		if got := eloChange(c.x, c.o, c.score); got != c.want {
+			t.Errorf("eloChange(%d, %d, %v) = %d, want %d", c.x, c.o, c.score, got, c.want)
+		}
+	}
+}
+
+func TestSignIn_RegistersThenChecksKey(t *testing.T) {
+	a := newAccounts()
+
+	info, err := a.SignIn("Alice", "")
+	if err != nil || info.Key == "" || info.Rating != InitialRating {
+		t.Fatalf("expected a new account with a key, got %+v, %v", info, err)
+	}
+
+	if _, err := a.SignIn("alice", ""); err != ErrNameTaken {
+		t.Errorf("expected the name to be taken, got %v", err)
+	}
+	if _, err := a.SignIn("alice", "guess"); err != ErrAccountKey {
+		t.Errorf("expected a wrong key, got %v", err)
+	}
+	again, err := a.SignIn("ALICE", info.Key)
+	if err != nil || again.Name != "Alice" || again.Key != "" {
+		t.Errorf("expected to sign back in as Alice without a new key, got %+v, %v", again, err)
+	}
+	if _, err := a.SignIn("  ", ""); err != ErrAccountName {
+		t.Errorf("expected a blank name to be rejected, got %v", err)
+	}
+}
+
+func TestLoadAccounts_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	a, err := loadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := a.SignIn("alice", "")
+	a.SignIn("bob", "")
+	a.Record(RatedGame{GameID: "g1", X: "alice", O: "bob", Winner: "X"})
+
+	b, err := loadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SignIn("alice", alice.Key); err != nil {
+		t.Errorf("expected alice's key to survive a reload: %v", err)
+	}
+	board := b.Leaderboard(10)
+	if len(board) != 2 || board[0].Name != "alice" || board[0].Rating != InitialRating+16 || board[1].Losses != 1 {
+		t.Errorf("unexpected leaderboard after reload: %+v", board)
+	}
+	if games := b.Games("Bob", 10); len(games) != 1 || games[0].Change != 16 {
+		t.Errorf("expected bob's game to be kept, got %+v", games)
+	}
+}
+
+func TestRateGame_OnlyBetweenTwoAccounts(t *testing.T) {
+	accounts = newAccounts()
+	defer func() { accounts = newAccounts() }()
+	aliceInfo, _ := accounts.SignIn("alice", "")
+	bobInfo, _ := accounts.SignIn("bob", "")
+
+	r := newRoom("rated-test", RoomOptions{})
+	alice, bob := &Client{Role: "X"}, &Client{Role: "O"}
+	signIn(alice, aliceInfo)
+	r.takeSeat(alice, "X")
+	r.takeSeat(bob, "O")
+
+	// Bob signs in before the game starts, then walks out partway through
+	r.handleSignIn(bob, "bob", bobInfo.Key)
+	r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: "X"})
+	r.freeSeat("O")
+	r.recordEnd()
+
+	if info, _ := accounts.Lookup("alice"); info.Rating != InitialRating+16 || info.Wins != 1 {
+		t.Errorf("expected alice to gain for the abandonment, got %+v", info)
+	}
+	if info, _ := accounts.Lookup("bob"); info.Rating != InitialRating-16 || info.Losses != 1 {
+		t.Errorf("expected bob to lose for walking out, got %+v", info)
+	}
+
+	// A guest in the empty seat doesn't make a rated game
+	r.takeSeat(&Client{Role: "O"}, "O")
+	r.resetGame()
+	r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: "X"})
+	r.handleResign(alice)
+	r.recordEnd()
+	if info, _ := accounts.Lookup("alice"); info.Games != 1 {
+		t.Errorf("expected a game against a guest to be unrated, got %+v", info)
+	}
+}
+
+func TestSetName_RegisteredNameNeedsSignIn(t *testing.T) {
+	accounts = newAccounts()
+	defer func() { accounts = newAccounts() }()
+	accounts.SignIn("alice", "")
+
+	r := newRoom("name-test", RoomOptions{})
+	guest := &Client{Role: "X"}
+	r.takeSeat(guest, "X")
+
+	r.handleSetName(guest, "ALICE")
+	if guest.Name != "" {
+		t.Errorf("expected a guest not to take a registered name, got %q", guest.Name)
+	}
+}
@@ -0,0 +1,136 @@
+// Package ai plays Grid Wars. Each Strategy looks at a game and picks the
+// move or attack it would make (or, in a draft, the class to pick); rolling
+// dice needs no decisions, so callers only ask when it's the player's turn
+// and no combat is waiting.
+package ai
+
+import "go-multiplayer/gridwars"
+
+// Strategy picks an action for mark, who is on turn. ok is false when there
+// is nothing legal to do.
+type Strategy interface {
+	Choose(s gridwars.State, mark string) (action gridwars.Action, ok bool)
+}
+
+// Difficulty levels, weakest first
+const (
+	LevelEasy   = "easy"   // Random
+	LevelMedium = "medium" // Greedy
+	LevelHard   = "hard"   // Search
+)
+
+// Levels lists the difficulty levels, weakest first
+var Levels = []string{LevelEasy, LevelMedium, LevelHard}
+
+// ForLevel returns the strategy for a difficulty level. rng breaks ties.
+func ForLevel(level string, rng gridwars.Rand) (Strategy, bool) {
+	switch level {
+	case LevelEasy:
+		return Random{rng}, true
+	case LevelMedium:
+		return Greedy{rng}, true
+	case LevelHard:
+		return Search{rng}, true
+	}
+	return nil, false
+}
+
+// Random plays any legal action
+type Random struct {
+	Rand gridwars.Rand
+}
+
+func (r Random) Choose(s gridwars.State, mark string) (gridwars.Action, bool) {
+	actions := s.LegalActions(mark)
+	if len(actions) == 0 {
+		return gridwars.Action{}, false
+	}
+	return actions[r.Rand.IntN(len(actions))], true
+}
+
+// Greedy hunts power-ups and attacks whenever the fight looks even or better.
+// With nothing to pick up it closes in on the nearest enemy.
+type Greedy struct {
+	Rand gridwars.Rand
+}
+
+func (g Greedy) Choose(s gridwars.State, mark string) (gridwars.Action, bool) {
+	// Any squad will do
+	if s.Draft != nil {
+		return Random{g.Rand}.Choose(s, mark)
+	}
+
+	actions := s.LegalActions(mark)
+	if len(actions) == 0 {
+		return gridwars.Action{}, false
+	}
+
+	var moves []gridwars.Action
+	for _, a := range actions {
+		if a.Type != gridwars.ActionAttack {
+			moves = append(moves, a)
+			continue
+		}
+		unit, enemy := s.Unit(a.Unit), s.UnitAt(a.X, a.Y)
+		if unit.AttackBoost || unit.HP >= enemy.HP {
+			return a, true
+		}
+	}
+	if len(moves) == 0 {
+		return gridwars.Action{}, false
+	}
+
+	// Head for the nearest power-up, or the enemy if there are none
+	var targets [][2]int
+	for _, p := range s.PowerUps {
+		targets = append(targets, [2]int{p.X, p.Y})
+	}
+	if len(targets) == 0 {
+		targets = squares(s.Squad(gridwars.Other(mark)))
+	}
+
+	var best []gridwars.Action
+	bestDistance := -1
+	for _, a := range moves {
+		d := nearest(a.X, a.Y, targets)
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = nil, d
+		}
+		if d == bestDistance {
+			best = append(best, a)
+		}
+	}
+	return best[g.Rand.IntN(len(best))], true
+}
+
+// nearest returns the distance from x,y to the closest target
+func nearest(x, y int, targets [][2]int) int {
+	closest := -1
+	for _, t := range targets {
+		if d := distance(x, y, t[0], t[1]); closest < 0 || d < closest {
+			closest = d
+		}
+	}
+	return closest
+}
+
+// squares lists where units stand
+func squares(units []*gridwars.Unit) [][2]int {
+	var list [][2]int
+	for _, u := range units {
+		list = append(list, [2]int{u.X, u.Y})
+	}
+	return list
+}
+
+// distance is how many moves apart two squares are (Chebyshev distance)
+func distance(x1, y1, x2, y2 int) int {
+	return max(abs(x1-x2), abs(y1-y2))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	mrand "math/rand/v2"
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func newRand(seed uint64) *mrand.Rand {
+	return mrand.New(mrand.NewPCG(seed, seed))
+}
+
+func TestForLevel(t *testing.T) {
+	for _, level := range Levels {
+		if _, ok := ForLevel(level, newRand(1)); !ok {
+			t.Errorf("no strategy for %q", level)
+		}
+	}
+	if _, ok := ForLevel("impossible", newRand(1)); ok {
+		t.Error("expected unknown level to be rejected")
+	}
+}
+
+func TestStrategies_PlayWholeGames(t *testing.T) {
+	rng := newRand(3)
+	// Search is slow on squad boards, so those games are cut short and only
+	// the blitz game runs with -short
+	games := []struct {
+		rules gridwars.Rules
+		turns int
+	}{{gridwars.Blitz, 200}, {gridwars.Squads, 30}, {gridwars.Tactics, 30}, {gridwars.Skirmish, 20}, {gridwars.Wilds, 20}}
+	if testing.Short() {
+		games = games[:1]
+	}
+	for _, game := range games {
+		rules := game.rules
+		// Every level plays both sides once: easy vs medium, medium vs hard, hard vs easy
+		for i, x := range Levels {
+			o := Levels[(i+1)%len(Levels)]
+			players := map[string]Strategy{}
+			players["X"], _ = ForLevel(x, rng)
+			players["O"], _ = ForLevel(o, rng)
+
+			s := gridwars.NewState(rules)
+			for turn := 0; turn < game.turns && s.Winner == ""; turn++ {
+				action := gridwars.Action{Type: gridwars.ActionRoll}
+				if s.Combat == nil {
+					mark := s.Turn
+					if s.Draft != nil {
+						mark = s.Draft.Turn
+					}
+					var ok bool
+					if action, ok = players[mark].Choose(s, mark); !ok {
+						t.Fatalf("%s: %s vs %s: %s had nothing to do", rules.Name, x, o, mark)
+					}
+				} else if s.Combat.AttackerRolled {
+					action.Mark = s.Combat.DefenderMark
+				} else {
+					action.Mark = s.Combat.AttackerMark
+				}
+
+				var err error
+				if s, _, err = gridwars.Apply(s, action, rng); err != nil {
+					t.Fatalf("%s: %s vs %s: illegal %+v: %v", rules.Name, x, o, action, err)
+				}
+			}
+		}
+	}
+}
+
+func TestGreedy_HeadsForPowerUp(t *testing.T) {
+	s := gridwars.NewState(gridwars.Classic)
+	s.PowerUps = []gridwars.PowerUp{{Type: "hp", X: 3, Y: 5}}
+
+	action, ok := Greedy{newRand(1)}.Choose(s, "X")
+	if !ok || action.X != 3 || action.Y != 5 {
+		t.Errorf("expected a move onto the power-up, got %+v", action)
+	}
+}
+
+func TestChoose_NextToTheEnemy(t *testing.T) {
+	// O right next to X, with X to move
+	start := gridwars.NewState(gridwars.Classic)
+	o := start.Unit("O1")
+	start.Board[o.Y][o.X] = ""
+	o.X, o.Y = 1, gridwars.Classic.BoardSize-2
+	start.Board[o.Y][o.X] = o.ID
+
+	t.Run("greedy attacks only when ahead", func(t *testing.T) {
+		s := start.Clone()
+		if action, _ := (Greedy{newRand(1)}).Choose(s, "X"); action.Type != gridwars.ActionAttack {
+			t.Errorf("expected an even fight to be taken, got %+v", action)
+		}
+
+		s.Unit("X1").HP = 2
+		if action, _ := (Greedy{newRand(1)}).Choose(s, "X"); action.Type == gridwars.ActionAttack {
+			t.Error("expected no attack when behind")
+		}
+	})
+
+	t.Run("search takes the kill", func(t *testing.T) {
+		s := start.Clone()
+		s.Unit("X1").AttackBoost = true
+		s.Unit("O1").HP = gridwars.Classic.BoostDamage
+
+		action, ok := Search{newRand(1)}.Choose(s, "X")
+		if !ok || action.Type != gridwars.ActionAttack {
+			t.Errorf("expected a winning boosted attack, got %+v", action)
+		}
+	})
+
+	t.Run("search runs from a losing fight", func(t *testing.T) {
+		// Badly hurt next to an enemy with a boost - staying put loses
+		s := start.Clone()
+		s.Unit("X1").HP = 2
+		s.Unit("O1").AttackBoost = true
+
+		action, ok := Search{newRand(1)}.Choose(s, "X")
+		if !ok || action.Type != gridwars.ActionMove {
+			t.Fatalf("expected a move, got %+v", action)
+		}
+		// O can only attack this turn if X is still within reach
+		if distance(action.X, action.Y, s.Unit("O1").X, s.Unit("O1").Y) <= gridwars.Classic.AttackRange {
+			t.Errorf("expected X to get away, moved to %d,%d", action.X, action.Y)
+		}
+	})
+}
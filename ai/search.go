@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"math"
+
+	"go-multiplayer/gridwars"
+)
+
+// Search looks a full turn ahead. It tries every action, weighs attacks over
+// every dice outcome and assumes the opponent answers with whatever hurts it
+// most. Power-ups that might spawn along the way aren't considered.
+type Search struct {
+	Rand gridwars.Rand
+}
+
+func (b Search) Choose(s gridwars.State, mark string) (gridwars.Action, bool) {
+	if s.Draft != nil {
+		return Random{b.Rand}.Choose(s, mark)
+	}
+
+	var best []gridwars.Action
+	bestScore := math.Inf(-1)
+	for _, a := range s.LegalActions(mark) {
+		score := expected(s, a, func(next gridwars.State) float64 {
+			return worstReply(next, mark)
+		})
+		if score > bestScore+1e-9 {
+			best, bestScore = nil, score
+		}
+		if score >= bestScore-1e-9 {
+			best = append(best, a)
+		}
+	}
+	if len(best) == 0 {
+		return gridwars.Action{}, false
+	}
+	return best[b.Rand.IntN(len(best))], true
+}
+
+// worstReply scores a state by the opponent's best answer to it, from me's
+// point of view
+func worstReply(s gridwars.State, me string) float64 {
+	worst := evaluate(s, me)
+	if s.Winner != "" {
+		return worst
+	}
+	replies := s.LegalActions(gridwars.Other(me))
+	if len(replies) > 0 {
+		worst = math.Inf(1)
+	}
+	for _, a := range replies {
+		worst = min(worst, expected(s, a, func(next gridwars.State) float64 {
+			return evaluate(next, me)
+		}))
+	}
+	return worst
+}
+
+// expected is the average score of the states an action can lead to. Only
+// attacks that roll dice have more than one.
+func expected(s gridwars.State, a gridwars.Action, score func(gridwars.State) float64) float64 {
+	if a.Type != gridwars.ActionAttack || s.Unit(a.Unit).AttackBoost {
+		next, _, err := gridwars.Apply(s, a, &script{})
+		if err != nil {
+			return math.Inf(-1)
+		}
+		return score(next)
+	}
+
+	// Most of the 36 rolls come out the same - only who won and by how much matter
+	type result struct {
+		attackerWon bool
+		damage      int
+	}
+	attackBonus := s.ClassOf(s.Unit(a.Unit)).AttackBonus
+	defendBonus := s.DefenseBonus(s.UnitAt(a.X, a.Y))
+	seen := make(map[result]float64)
+	total := 0.0
+	for attackRoll := 1; attackRoll <= 6; attackRoll++ {
+		for defendRoll := 1; defendRoll <= 6; defendRoll++ {
+			attack, defend := attackRoll+attackBonus, defendRoll+defendBonus
+			key := result{attack >= defend, max(abs(attack-defend), 1)}
+			if _, ok := seen[key]; !ok {
+				seen[key] = score(fight(s, a, attackRoll, defendRoll))
+			}
+			total += seen[key]
+		}
+	}
+	return total / 36
+}
+
+// fight plays out an attack with known dice
+func fight(s gridwars.State, a gridwars.Action, attackRoll, defendRoll int) gridwars.State {
+	s, _, _ = gridwars.Apply(s, a, &script{attackRoll - 1, defendRoll - 1})
+	s, _, _ = gridwars.Apply(s, gridwars.Action{Type: gridwars.ActionRoll, Mark: a.Mark}, &script{})
+	s, _, _ = gridwars.Apply(s, gridwars.Action{Type: gridwars.ActionRoll, Mark: gridwars.Other(a.Mark)}, &script{})
+	return s
+}
+
+// script is a Rand that hands out its numbers in order, then always the
+// highest possible - which never spawns a power-up
+type script []int
+
+func (r *script) IntN(n int) int {
+	if len(*r) == 0 {
+		return n - 1
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v
+}
+
+// winScore is how evaluate scores a won game
+const winScore = 1000
+
+// evaluate scores a state for me: HP lead across the squads first, then attack
+// boosts held, then being closer to the power-ups than the enemy
+func evaluate(s gridwars.State, me string) float64 {
+	switch s.Winner {
+	case "":
+	case me:
+		return winScore
+	case gridwars.WinnerDraw:
+		return 0
+	default:
+		return -winScore
+	}
+
+	mine, theirs := s.Squad(me), s.Squad(gridwars.Other(me))
+	boost := 5 * float64(s.Rules.BoostDamage)
+	score := 0.0
+	for _, u := range mine {
+		score += 10 * float64(u.HP)
+		if u.AttackBoost {
+			score += boost
+		}
+	}
+	for _, u := range theirs {
+		score -= 10 * float64(u.HP)
+		if u.AttackBoost {
+			score -= boost
+		}
+	}
+
+	for _, p := range s.PowerUps {
+		score += 0.5 * float64(nearest(p.X, p.Y, squares(theirs))-nearest(p.X, p.Y, squares(mine)))
+	}
+	return score
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	mrand "math/rand/v2"
+	"time"
+
+	"go-multiplayer/ai"
+	"go-multiplayer/gridwars"
+)
+
+// BotDelay is how long a bot waits before acting, so people can follow along
+const BotDelay = 800 * time.Millisecond
+
+// ErrBotLevel is returned for a difficulty level that doesn't exist
+var ErrBotLevel = errors.New(`Unknown bot level (use "easy", "medium" or "hard")`)
+
+// Bot is a server-run player. It sits in a seat as a Client with no
+// connection and acts through handleAction like anyone else.
+type Bot struct {
+	Level    string
+	strategy ai.Strategy
+	actAt    time.Time // When the bot acts on what it's waiting to do (zero = nothing to do)
+}
+
+// newBotClient creates a bot player at a difficulty level
+func newBotClient(level string) (*Client, error) {
+	strategy, ok := ai.ForLevel(level, mrand.New(mrand.NewPCG(randomSeed(), randomSeed())))
+	if !ok {
+		return nil, ErrBotLevel
+	}
+	return &Client{Name: "Bot (" + level + ")", bot: &Bot{Level: level, strategy: strategy}}, nil
+}
+
+// isBot reports whether a seat is held by a server-run bot
+func (s *Seat) isBot() bool {
+	return s.Client != nil && s.Client.bot != nil
+}
+
+// isBot reports whether a client is a program rather than a person
+func (c *Client) isBot() bool {
+	return c.bot != nil || c.api
+}
+
+// handleAddBot seats a bot opposite the player who asked for one
+func (r *Room) handleAddBot(client *Client, level string) {
+	if client.Role != "X" && client.Role != "O" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Only players can add a bot"})
+		return
+	}
+	mark := gridwars.Other(client.Role)
+	if r.seatFor(mark) != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Both seats are taken"})
+		return
+	}
+	bot, err := newBotClient(level)
+	if err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+		return
+	}
+
+	bot.Role = mark
+	r.clients[bot] = true
+	r.takeSeat(bot, mark)
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: bot.Name + " joined as " + mark,
+	})
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+}
+
+// dismissBots frees bots' seats once there's no person left to play them
+func (r *Room) dismissBots() {
+	for _, seat := range r.seats {
+		if !seat.isBot() {
+			return
+		}
+	}
+	for _, mark := range []string{"X", "O"} {
+		if r.seatFor(mark) != nil {
+			r.freeSeat(mark)
+		}
+	}
+}
+
+// runBots lets any seated bot take its turn once it has waited BotDelay.
+// Only called from the room's goroutine.
+func (r *Room) runBots(now time.Time) {
+	for _, mark := range []string{"X", "O"} {
+		seat := r.seatFor(mark)
+		if seat == nil || !seat.isBot() {
+			continue
+		}
+		bot := seat.Client.bot
+		if !r.botShouldAct(mark) {
+			bot.actAt = time.Time{}
+			continue
+		}
+		if bot.actAt.IsZero() {
+			bot.actAt = now.Add(BotDelay)
+		}
+		if now.Before(bot.actAt) {
+			continue
+		}
+
+		bot.actAt = time.Time{}
+		if action, ok := r.botAction(seat.Client); ok {
+			r.handleAction(action)
+		}
+	}
+}
+
+// botShouldAct reports whether mark has something to do: answer a rematch,
+// roll their die, or take their turn against a seated opponent
+func (r *Room) botShouldAct(mark string) bool {
+	if r.proposal != nil {
+		return r.proposal.From != mark
+	}
+	if r.seatFor(gridwars.Other(mark)) == nil {
+		return false
+	}
+	return len(r.game.LegalActions(mark)) > 0
+}
+
+// botAction decides what a bot does now that it's its move. Bots always
+// agree to rematches.
+func (r *Room) botAction(client *Client) (Action, bool) {
+	switch {
+	case r.proposal != nil:
+		return Action{Type: ActionAcceptRematch, Client: client}, true
+	case r.game.Combat != nil:
+		return Action{Type: ActionRoll, Client: client}, true
+	}
+
+	choice, ok := client.bot.strategy.Choose(r.game.State, client.Role)
+	if !ok {
+		return Action{}, false
+	}
+	action := Action{Type: ActionMove, Client: client, Unit: choice.Unit, X: choice.X, Y: choice.Y}
+	switch choice.Type {
+	case gridwars.ActionAttack:
+		action.Type = ActionAttack
+	case gridwars.ActionPick:
+		action = Action{Type: ActionPick, Client: client, Class: choice.Class}
+	}
+	return action, true
+}
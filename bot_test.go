@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newBotRoom seats a player as X and a bot as O
+func newBotRoom(id, level string) (*Room, *Client) {
+	r := newRoom(id, RoomOptions{})
+	x := &Client{Role: "X"}
+	r.clients[x] = true
+	r.takeSeat(x, "X")
+	r.handleAddBot(x, level)
+	return r, x
+}
+
+func TestAddBot_TakesTheFreeSeat(t *testing.T) {
+	r, x := newBotRoom("bot-seat-test", "easy")
+
+	seat := r.seatFor("O")
+	if seat == nil || !seat.isBot() || seat.Client.Role != "O" {
+		t.Fatalf("expected a bot in O's seat, got %+v", seat)
+	}
+	r.publishInfo()
+	bots := 0
+	for _, p := range r.Info().Players {
+		if p.Bot {
+			bots++
+		}
+	}
+	if bots != 1 {
+		t.Errorf("expected the lobby to show one bot, got %d", bots)
+	}
+
+	// No room for a second one
+	r.handleAddBot(x, "hard")
+	if r.seatFor("O").Client != seat.Client {
+		t.Error("second bot should not replace the first")
+	}
+
+	// Spectators can't summon bots, and levels must exist
+	r.freeSeat("O")
+	r.handleAddBot(&Client{Role: "spectator"}, "easy")
+	r.handleAddBot(x, "impossible")
+	if r.seatFor("O") != nil {
+		t.Error("expected no bot seated")
+	}
+}
+
+func TestRunBots_PlaysItsTurnAfterDelay(t *testing.T) {
+	r, x := newBotRoom("bot-turn-test", "medium")
+	r.handleMoveAction(x, "", 1, r.game.Rules.BoardSize-1)
+
+	now := time.Now()
+	r.runBots(now)
+	if r.game.Turn != "O" {
+		t.Fatal("expected O to move")
+	}
+	r.runBots(now.Add(BotDelay / 2))
+	if r.game.Turn != "O" {
+		t.Fatal("bot moved before its delay")
+	}
+	r.runBots(now.Add(BotDelay))
+	if r.game.Turn != "X" || r.game.Turns != 2 {
+		t.Errorf("expected the bot to have moved, turn %s after %d turns", r.game.Turn, r.game.Turns)
+	}
+}
+
+func TestRunBots_RollsAndAcceptsRematch(t *testing.T) {
+	r, x := newBotRoom("bot-roll-test", "easy")
+	placeUnit(r.game, "O1", 1, r.game.Rules.BoardSize-2)
+	r.handleAttackAction(x, "", 1, r.game.Rules.BoardSize-2)
+	r.handleRollAction(x)
+
+	now := time.Now()
+	r.runBots(now)
+	r.runBots(now.Add(BotDelay))
+	if r.game.Combat != nil {
+		t.Fatal("expected the bot to roll and settle the combat")
+	}
+
+	r.handleResign(x)
+	r.handleRematchAction(x)
+	r.runBots(now)
+	r.runBots(now.Add(BotDelay))
+	if r.game.Winner != "" || r.proposal != nil {
+		t.Error("expected the bot to accept the rematch")
+	}
+	if seat := r.seatFor("X"); seat == nil || !seat.isBot() {
+		t.Error("expected seats swapped for the rematch")
+	}
+}
+
+func TestDismissBots_WhenThePlayerLeaves(t *testing.T) {
+	r, x := newBotRoom("bot-leave-test", "hard")
+	bot := r.seatFor("O").Client
+
+	r.handleLeave(x, false)
+
+	if r.seatFor("O") != nil || r.clients[bot] {
+		t.Error("expected the bot to leave with its opponent")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// handleBotSocket serves /bot, the WebSocket endpoint for programs. It speaks
+// the same protocol as /ws, but the connection has to present an API token
+// ("Authorization: Bearer <token>"), plays under the name the token was
+// issued for, and gets the legal actions for its seat with every game update.
+func handleBotSocket(w http.ResponseWriter, r *http.Request) {
+	name, ok := botForToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if !ok {
+		http.Error(w, "Invalid bot token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("Upgrade error:", err)
+		return
+	}
+	defer conn.Close()
+
+	fmt.Println("Bot connected:", name)
+	serveClient(&Client{Conn: conn, Name: name, api: true}, r.URL.Query())
+}
+
+// botForToken returns the name of the bot an API token was issued to
+func botForToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for candidate, name := range botTokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// parseBotTokens reads "name:token" pairs separated by commas, skipping any
+// that are malformed
+func parseBotTokens(s string) map[string]string {
+	tokens := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || token == "" || len(name) > MaxNameLength {
+			fmt.Printf("Ignoring invalid BOT_TOKENS entry for %q\n", name)
+			continue
+		}
+		tokens[token] = name
+	}
+	return tokens
+}
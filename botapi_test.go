@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseBotTokens(t *testing.T) {
+	tokens := parseBotTokens("alphabot:s3cret, betabot:hunter2,broken,:nameless,empty:")
+
+	if len(tokens) != 2 || tokens["s3cret"] != "alphabot" || tokens["hunter2"] != "betabot" {
+		t.Errorf("unexpected tokens: %v", tokens)
+	}
+}
+
+func TestBotSocket_RejectsBadToken(t *testing.T) {
+	botTokens = map[string]string{"s3cret": "alphabot"}
+	defer func() { botTokens = map[string]string{} }()
+
+	for _, header := range []string{"", "Bearer wrong", "s3cret!"} {
+		req := httptest.NewRequest("GET", "/bot", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		handleBotSocket(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: expected 401, got %d", header, w.Code)
+		}
+	}
+}
+
+func TestBotSocket_TaggedAndToldLegalActions(t *testing.T) {
+	botTokens = map[string]string{"s3cret": "alphabot"}
+	defer func() { botTokens = map[string]string{} }()
+
+	server := httptest.NewServer(http.HandlerFunc(handleBotSocket))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/bot?room=bot-api-test"
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer s3cret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var assigned, state ServerMessage
+	if err := conn.ReadJSON(&assigned); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	if assigned.Type != "assigned" || !assigned.Bot || assigned.Mark != "X" {
+		t.Errorf("expected to be seated as X and tagged a bot, got %+v", assigned)
+	}
+	if state.Type != "state" || len(state.Legal) == 0 {
+		t.Fatalf("expected a state with legal actions, got %+v", state)
+	}
+	if state.Hints == nil || state.Hints.Mark != "X" || len(state.Hints.Moves) != len(state.Legal) {
+		t.Errorf("expected hints matching X's moves, got %+v", state.Hints)
+	}
+	for _, action := range state.Legal {
+		if action.Mark != "X" {
+			t.Errorf("expected only X's actions, got %+v", action)
+		}
+	}
+}
+
+func TestBotSocket_CantSignInFromLobby(t *testing.T) {
+	human, err := accounts.SignIn("lobby-sign-in-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := &Client{Name: "alphabot", api: true}
+	handleLobbyMessage(bot, ClientMessage{Type: string(ActionSignIn), Name: human.Name, Key: human.Key})
+	if bot.account != "" || bot.Name != "alphabot" {
+		t.Errorf("expected the bot to keep its token's name, got %q signed in as %q", bot.Name, bot.account)
+	}
+}
@@ -0,0 +1,385 @@
+// Package client talks to a Grid Wars server over its WebSocket protocol, for
+// writing bots and tools in Go. It keeps a local copy of the game up to date
+// and calls back when things happen:
+//
+//	c, err := client.Dial("ws://localhost:8080/ws?room=main")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	c.OnState = func(g *client.Game) {
+//		if actions := c.Legal(); len(actions) > 0 {
+//			c.Do(actions[0])
+//		}
+//	}
+//	log.Fatal(c.Run())
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"go-multiplayer/gridwars"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is one connection to the server. Set the On* callbacks before
+// calling Run; they're called from Run's goroutine, after the local copy of
+// the game has been updated, and may send actions.
+type Client struct {
+	OnAssigned      func(mark string)                // Seated as "X" or "O", or watching as "spectator"
+	OnState         func(game *Game)                 // Game changed
+	OnCombatStart   func(combat *Combat)             // Attack made - both sides need to roll
+	OnCombatRolled  func(combat *Combat)             // A combatant rolled
+	OnCombat        func(combat *Combat, game *Game) // Combat resolved, dice revealed
+	OnCombatBoosted func(combat *Combat, game *Game) // Boosted attack landed without dice
+	OnDraft         func(draft *Draft)               // Someone picked, or the draft's timer started
+	OnChat          func(from, name, message string) // Chat or system message
+	OnError         func(message string)             // Server rejected something we sent
+	OnUpdate        func(update *Update)             // Every message, including the above
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu     sync.Mutex // Guards everything below
+	mark   string
+	room   string
+	token  string
+	bot    bool
+	game   *Game
+	combat *Combat
+	draft  *Draft
+	hints  *gridwars.Hints
+	legal  []gridwars.Action
+}
+
+// Dial connects to a server's /ws endpoint, e.g. "ws://localhost:8080/ws".
+// Add ?room=<id> to go straight into a room.
+func Dial(url string) (*Client, error) {
+	return dial(url, nil)
+}
+
+// DialBot connects to a server's /bot endpoint with an API token, e.g.
+// "ws://localhost:8080/bot". Bots are told their legal actions with every update.
+func DialBot(url, token string) (*Client, error) {
+	return dial(url, http.Header{"Authorization": {"Bearer " + token}})
+}
+
+func dial(url string, header http.Header) (*Client, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, ErrUnauthorized
+		}
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// ErrUnauthorized is returned by DialBot when the server doesn't accept the token
+var ErrUnauthorized = errors.New("client: bot token rejected")
+
+// Run reads from the server, keeping the game up to date and calling the
+// callbacks, until the connection closes. It returns the error that closed it.
+func (c *Client) Run() error {
+	for {
+		var u Update
+		if err := c.conn.ReadJSON(&u); err != nil {
+			return err
+		}
+		c.handle(&u)
+	}
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// handle applies an update to the local state, then calls back
+func (c *Client) handle(u *Update) {
+	c.mu.Lock()
+	switch u.Type {
+	case "assigned":
+		c.mark, c.room, c.token, c.bot = u.Mark, u.Room, u.Token, u.Bot
+	case "leftRoom":
+		c.mark, c.room, c.token, c.game, c.combat, c.draft, c.hints = "", "", "", nil, nil, nil, nil
+	case "draft_state":
+		c.draft = u.Draft
+	case "combat_start", "combat_rolled":
+		c.combat = u.Combat
+	case "combat", "combat_boosted":
+		c.combat = nil
+	}
+	if u.Game != nil {
+		if c.game == nil || c.game.ID != u.Game.ID {
+			c.combat, c.draft = nil, nil // New game
+		}
+		c.game = u.Game
+		c.hints = u.Hints
+
+		// The game has the latest picks, but only draft_state has the deadline
+		if u.Game.Draft == nil {
+			c.draft = nil
+		} else if c.draft != nil {
+			c.draft = &Draft{Draft: *u.Game.Draft, Deadline: c.draft.Deadline}
+		}
+	}
+	if u.Legal != nil || u.Game != nil || u.Combat != nil || u.Draft != nil {
+		c.legal = u.Legal
+	}
+	c.mu.Unlock()
+
+	switch u.Type {
+	case "assigned":
+		call(c.OnAssigned, u.Mark)
+	case "state":
+		call(c.OnState, u.Game)
+	case "combat_start":
+		call(c.OnCombatStart, u.Combat)
+	case "combat_rolled":
+		call(c.OnCombatRolled, u.Combat)
+	case "combat":
+		call2(c.OnCombat, u.Combat, u.Game)
+	case "combat_boosted":
+		call2(c.OnCombatBoosted, u.Combat, u.Game)
+	case "draft_state":
+		call(c.OnDraft, u.Draft)
+	case "chat":
+		if c.OnChat != nil {
+			c.OnChat(u.From, u.Name, u.Message)
+		}
+	case "error":
+		call(c.OnError, u.Error)
+	}
+	call(c.OnUpdate, u)
+}
+
+func call[T any](f func(T), v T) {
+	if f != nil {
+		f(v)
+	}
+}
+
+func call2[T, U any](f func(T, U), v T, w U) {
+	if f != nil {
+		f(v, w)
+	}
+}
+
+// Mark is our role in the current room: "X", "O", "spectator", or "" in the lobby
+func (c *Client) Mark() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mark
+}
+
+// Room is the ID of the room we're in, or "" in the lobby
+func (c *Client) Room() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+// Token is the session token for resuming our seat after a disconnect
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Game returns a copy of the latest game, or nil if we aren't in a room yet.
+// While a combat is waiting on dice, State.Combat says who is fighting and
+// who has rolled. During a draft, State.Draft has every pick so far.
+func (c *Client) Game() *Game {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.game == nil {
+		return nil
+	}
+	game := *c.game
+	if c.draft != nil {
+		game.State.Draft = &c.draft.Draft
+	}
+	game.State = game.State.Clone()
+	if c.combat != nil {
+		game.State.Combat = &gridwars.Combat{
+			AttackerMark:   c.combat.AttackerMark,
+			DefenderMark:   c.combat.DefenderMark,
+			AttackerUnit:   c.combat.AttackerUnit,
+			DefenderUnit:   c.combat.DefenderUnit,
+			AttackerBonus:  c.combat.AttackerBonus,
+			DefenderBonus:  c.combat.DefenderBonus,
+			AttackerRolled: c.combat.AttackerRolled,
+			DefenderRolled: c.combat.DefenderRolled,
+		}
+	}
+	return &game
+}
+
+// Combat returns the combat waiting on dice, if any
+func (c *Client) Combat() *Combat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.combat == nil {
+		return nil
+	}
+	combat := *c.combat
+	return &combat
+}
+
+// Draft returns the draft in progress, if any
+func (c *Client) Draft() *Draft {
+	game := c.Game()
+	if game == nil || game.Draft == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	draft := &Draft{Draft: *game.Draft}
+	if c.draft != nil {
+		draft.Deadline = c.draft.Deadline
+	}
+	return draft
+}
+
+// Hints are the server's list of legal moves and attack targets for the
+// player to move, or nil if nobody can act
+func (c *Client) Hints() *gridwars.Hints {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hints
+}
+
+// Legal lists what we can do right now. Bots get this from the server; for
+// everyone else it's worked out from the local copy of the game.
+func (c *Client) Legal() []gridwars.Action {
+	c.mu.Lock()
+	bot, legal, mark := c.bot, c.legal, c.mark
+	c.mu.Unlock()
+	if bot {
+		return append([]gridwars.Action(nil), legal...)
+	}
+	game := c.Game()
+	if game == nil {
+		return nil
+	}
+	return game.LegalActions(mark)
+}
+
+// Send sends any message to the server
+func (c *Client) Send(msg Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(msg)
+}
+
+// Move moves our unit to x,y. With more than one unit left, use MoveUnit.
+func (c *Client) Move(x, y int) error {
+	return c.MoveUnit("", x, y)
+}
+
+// MoveUnit moves one of our units (by ID) to x,y
+func (c *Client) MoveUnit(unit string, x, y int) error {
+	return c.Send(Message{Type: "move", Unit: unit, X: x, Y: y})
+}
+
+// Attack attacks the enemy unit at x,y. With more than one unit left, use
+// AttackWith.
+func (c *Client) Attack(x, y int) error {
+	return c.AttackWith("", x, y)
+}
+
+// AttackWith attacks the enemy unit at x,y with one of our units (by ID)
+func (c *Client) AttackWith(unit string, x, y int) error {
+	return c.Send(Message{Type: "attack", Unit: unit, X: x, Y: y})
+}
+
+// Roll rolls our die in a pending combat
+func (c *Client) Roll() error {
+	return c.Send(Message{Type: "roll"})
+}
+
+// Pick drafts a class for our squad, or a random one if class is empty
+func (c *Client) Pick(class string) error {
+	return c.Send(Message{Type: "pick", Class: class})
+}
+
+// Do sends one of the actions from Legal
+func (c *Client) Do(action gridwars.Action) error {
+	switch action.Type {
+	case gridwars.ActionMove:
+		return c.MoveUnit(action.Unit, action.X, action.Y)
+	case gridwars.ActionAttack:
+		return c.AttackWith(action.Unit, action.X, action.Y)
+	case gridwars.ActionRoll:
+		return c.Roll()
+	case gridwars.ActionPick:
+		return c.Pick(action.Class)
+	case gridwars.ActionResign:
+		return c.Resign()
+	}
+	return errors.New("client: can't send a " + string(action.Type) + " action")
+}
+
+// Resign concedes the game
+func (c *Client) Resign() error {
+	return c.Send(Message{Type: "resign"})
+}
+
+// Rematch proposes (or agrees to) starting over
+func (c *Client) Rematch() error {
+	return c.Send(Message{Type: "rematch"})
+}
+
+// Chat says something to the room
+func (c *Client) Chat(text string) error {
+	return c.Send(Message{Type: "chat", Message: text})
+}
+
+// SetName sets our display name
+func (c *Client) SetName(name string) error {
+	return c.Send(Message{Type: "setName", Name: name})
+}
+
+// SignIn signs in to the account registered as name. An empty key registers
+// the name instead; the key comes back in the "account" update and is needed
+// to sign in again.
+func (c *Client) SignIn(name, key string) error {
+	return c.Send(Message{Type: "signIn", Name: name, Key: key})
+}
+
+// JoinRoom leaves the current room (if any) for another. code is the invite
+// code for a private room.
+func (c *Client) JoinRoom(room, code string) error {
+	return c.Send(Message{Type: "joinRoom", Room: room, Code: code})
+}
+
+// CreateRoom opens a new room and joins it. msg.Room, Private, Code,
+// TimeControl and Rules configure it; an empty Room gets a random ID.
+func (c *Client) CreateRoom(msg Message) error {
+	msg.Type = "createRoom"
+	return c.Send(msg)
+}
+
+// FindMatch leaves the current room and waits for matchmaking to seat us
+// opposite someone of similar rating. An "assigned" update arrives once it has.
+func (c *Client) FindMatch() error {
+	return c.Send(Message{Type: "findMatch"})
+}
+
+// CancelMatch stops waiting for a match
+func (c *Client) CancelMatch() error {
+	return c.Send(Message{Type: "cancelMatch"})
+}
+
+// LeaveRoom goes back to the lobby
+func (c *Client) LeaveRoom() error {
+	return c.Send(Message{Type: "leaveRoom"})
+}
+
+// AddBot seats a server-run bot ("easy", "medium" or "hard") opposite us
+func (c *Client) AddBot(level string) error {
+	return c.Send(Message{Type: "addBot", Level: level})
+}
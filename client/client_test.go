@@ -0,0 +1,225 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-multiplayer/gridwars"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer sends script to whoever connects, then passes on what they send
+func fakeServer(t *testing.T, script []string, received chan<- Message) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for _, msg := range script {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func stateJSON(t *testing.T, id string) string {
+	state := gridwars.NewState(gridwars.Classic)
+	data, err := json.Marshal(Game{ID: id, State: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `{"type":"state","game":` + string(data) + `}`
+}
+
+func TestClient_MirrorsGameAndCallsBack(t *testing.T) {
+	script := []string{
+		`{"type":"assigned","mark":"X","room":"main","token":"t0k"}`,
+		stateJSON(t, "g1"),
+		`{"type":"combat_start","combat":{"attackerMark":"X","defenderMark":"O"}}`,
+		`{"type":"combat_rolled","combat":{"attackerMark":"X","defenderMark":"O","attackerRolled":true}}`,
+		`{"type":"chat","from":"O","name":"Bob","message":"gl"}`,
+		`{"type":"combat","combat":{"attackerMark":"X","defenderMark":"O","attackerRoll":5,"defenderRoll":2,"winner":"attacker","damage":3,"loserMark":"O"},"game":` + strings.TrimPrefix(stateJSON(t, "g1"), `{"type":"state","game":`),
+	}
+	received := make(chan Message, 1)
+	server := fakeServer(t, script, received)
+	defer server.Close()
+
+	c, err := Dial(wsURL(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	c.OnAssigned = func(mark string) { calls = append(calls, "assigned "+mark) }
+	c.OnState = func(g *Game) { calls = append(calls, "state "+g.ID) }
+	c.OnCombatStart = func(*Combat) {
+		calls = append(calls, "combat_start")
+		if c.Game().Combat == nil || len(c.Legal()) != 1 || c.Legal()[0].Type != gridwars.ActionRoll {
+			t.Errorf("expected the attacker to have to roll, got %v", c.Legal())
+		}
+	}
+	c.OnCombatRolled = func(*Combat) {
+		calls = append(calls, "combat_rolled")
+		if len(c.Legal()) != 0 {
+			t.Errorf("expected nothing to do while the defender rolls, got %v", c.Legal())
+		}
+	}
+	c.OnChat = func(from, name, message string) { calls = append(calls, "chat "+name+": "+message) }
+	c.OnCombat = func(combat *Combat, g *Game) {
+		calls = append(calls, "combat "+combat.Winner)
+		if c.Game().Combat != nil {
+			t.Error("expected the combat to be cleared once resolved")
+		}
+		c.Close()
+	}
+	c.Run()
+
+	want := []string{"assigned X", "state g1", "combat_start", "combat_rolled", "chat Bob: gl", "combat attacker"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("expected callbacks %v, got %v", want, calls)
+	}
+	if c.Mark() != "X" || c.Room() != "main" || c.Token() != "t0k" {
+		t.Errorf("unexpected seat: %q %q %q", c.Mark(), c.Room(), c.Token())
+	}
+}
+
+func TestClient_LegalFromLocalGame(t *testing.T) {
+	received := make(chan Message, 1)
+	server := fakeServer(t, []string{`{"type":"assigned","mark":"X"}`, stateJSON(t, "g1")}, received)
+	defer server.Close()
+
+	c, err := Dial(wsURL(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.OnState = func(*Game) {
+		legal := c.Legal()
+		if len(legal) == 0 {
+			t.Error("expected X to have moves")
+			c.Close()
+			return
+		}
+		c.Do(legal[0])
+	}
+	go c.Run()
+
+	msg := <-received
+	state := gridwars.NewState(gridwars.Classic)
+	want := state.LegalActions("X")[0]
+	if msg.Type != "move" || msg.X != want.X || msg.Y != want.Y {
+		t.Errorf("expected %+v to be sent, got %+v", want, msg)
+	}
+}
+
+func TestClient_BotUsesServerLegal(t *testing.T) {
+	script := []string{
+		`{"type":"assigned","mark":"O","bot":true}`,
+		strings.TrimSuffix(stateJSON(t, "g1"), "}") + `,"legal":[{"type":"attack","mark":"O","x":3,"y":4}]}`,
+	}
+	received := make(chan Message, 1)
+	server := fakeServer(t, script, received)
+	defer server.Close()
+
+	c, err := Dial(wsURL(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.OnState = func(*Game) { c.Do(c.Legal()[0]) }
+	go c.Run()
+
+	if msg := <-received; msg.Type != "attack" || msg.X != 3 || msg.Y != 4 {
+		t.Errorf("expected the server's attack to be sent, got %+v", msg)
+	}
+}
+
+func TestDialBot_RejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Invalid bot token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := DialBot(wsURL(server), "wrong"); err != ErrUnauthorized {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestClient_PicksFromLatestDraft(t *testing.T) {
+	state := gridwars.NewState(gridwars.Skirmish)
+	start, err := json.Marshal(Game{ID: "g1", State: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A knight each so far, and O picks again in snake order
+	for _, mark := range []string{"X", "O"} {
+		if state, _, err = gridwars.Apply(state, gridwars.Action{Type: gridwars.ActionPick, Mark: mark, Class: "knight"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	picked, err := json.Marshal(Game{ID: "g1", State: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := []string{
+		`{"type":"assigned","mark":"O"}`,
+		`{"type":"state","game":` + string(start) + `}`,
+		`{"type":"draft_state","draft":{"pool":{"knight":1,"archer":2,"scout":2},"picks":{"X":["knight"],"O":[]},"turn":"O","deadline":1234}}`,
+		`{"type":"state","game":` + string(picked) + `}`,
+	}
+	received := make(chan Message, 1)
+	server := fakeServer(t, script, received)
+	defer server.Close()
+
+	c, err := Dial(wsURL(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.OnState = func(g *Game) {
+		if len(g.Draft.Picks["O"]) == 0 {
+			return
+		}
+		// The game's picks, with the deadline from draft_state
+		if draft := c.Draft(); draft == nil || draft.Deadline != 1234 || draft.Turn != "O" || draft.Pool["knight"] != 0 {
+			t.Errorf("expected the latest draft, got %+v", draft)
+		}
+		c.Do(c.Legal()[0])
+	}
+	go c.Run()
+
+	if msg := <-received; msg.Type != "pick" || msg.Class != "archer" {
+		t.Errorf("expected the first class left to be picked, got %+v", msg)
+	}
+}
+
+func TestCombat_VerifyChecksTheStartingCommitment(t *testing.T) {
+	commitment := gridwars.DiceCommitment(5, 3, "salt")
+	var result Combat
+	json.Unmarshal([]byte(`{"attackerRoll":5,"defenderRoll":3,"salt":"salt","commitment":"`+commitment+`"}`), &result)
+
+	if !result.Verify(commitment) {
+		t.Error("expected the revealed dice to match the commitment")
+	}
+	result.DefenderRoll = 6
+	if result.Verify(commitment) {
+		t.Error("expected changed dice not to verify")
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+
+	"go-multiplayer/gridwars"
+)
+
+// Message is what a client sends to the server. Only the fields its Type
+// uses need setting.
+type Message struct {
+	Type        string `json:"type"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Unit        string `json:"unit,omitempty"`        // move, attack: the unit acting
+	Class       string `json:"class,omitempty"`       // pick: the class to draft, empty for a random one
+	Message     string `json:"message,omitempty"`     // chat
+	Name        string `json:"name,omitempty"`        // setName
+	Room        string `json:"room,omitempty"`        // createRoom, joinRoom
+	Private     bool   `json:"private,omitempty"`     // createRoom
+	Code        string `json:"code,omitempty"`        // createRoom: password; joinRoom: invite code
+	Token       string `json:"token,omitempty"`       // joinRoom: session token to resume a held seat
+	GameID      string `json:"gameId,omitempty"`      // replay
+	TimeControl string `json:"timeControl,omitempty"` // createRoom, e.g. "clock:300+5"
+	Rules       string `json:"rules,omitempty"`       // createRoom, e.g. "blitz"
+	Level       string `json:"level,omitempty"`       // addBot
+	Key         string `json:"key,omitempty"`         // signIn: account key, empty to register
+}
+
+// Update is anything the server sends. Type says which fields are set.
+type Update struct {
+	Type     string          `json:"type"`
+	Game     *Game           `json:"game,omitempty"`
+	Mark     string          `json:"mark,omitempty"` // "assigned": "X", "O" or "spectator"
+	Error    string          `json:"error,omitempty"`
+	From     string          `json:"from,omitempty"` // "chat": "X", "O", "spectator" or "system"
+	Name     string          `json:"name,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	Combat   *Combat         `json:"combat,omitempty"`
+	Room     string          `json:"room,omitempty"`
+	Rooms    []RoomInfo      `json:"rooms,omitempty"`
+	Code     string          `json:"code,omitempty"`  // "invite": code for a private room
+	Token    string          `json:"token,omitempty"` // "assigned": session token to resume the seat
+	Proposal *Proposal       `json:"proposal,omitempty"`
+	Queue    []QueueEntry    `json:"queue,omitempty"`
+	Position int             `json:"position,omitempty"`
+	GameID   string          `json:"gameId,omitempty"`
+	Event    json.RawMessage `json:"event,omitempty"` // "replay": one logged event
+	Bot      bool            `json:"bot,omitempty"`   // "assigned": connected as a bot
+	Account  *Account        `json:"account,omitempty"`
+	Draft    *Draft          `json:"draft,omitempty"` // "draft_state": the draft so far
+
+	Hints *gridwars.Hints   `json:"hints,omitempty"` // With a game: moves and attack targets for the player to move
+	Legal []gridwars.Action `json:"legal,omitempty"` // Bots only: what can be done next
+}
+
+// Game is the server's view of a game: the rules' state plus the server's
+// extras. Pending combat dice aren't sent, so State.Combat only says who's
+// fighting and who has rolled (see Client.Game).
+type Game struct {
+	ID string `json:"id"`
+	gridwars.State
+	DrawOffer string `json:"drawOffer,omitempty"` // Mark of the player offering a draw
+	Clock     *Clock `json:"clock,omitempty"`     // Timed games only
+
+	Accounts map[string]string `json:"accounts,omitempty"` // Signed-in player by mark
+}
+
+// Draft is a draft in progress and when the server picks for whoever's next
+type Draft struct {
+	gridwars.Draft
+	Deadline int64 `json:"deadline,omitempty"` // Unix ms, zero until both players are seated
+}
+
+// Clock is each player's remaining time
+type Clock struct {
+	Control    string `json:"control"`    // e.g. "turn:30" or "clock:300+5"
+	RemainingX int64  `json:"remainingX"` // Milliseconds
+	RemainingO int64  `json:"remainingO"` // Milliseconds
+	Running    bool   `json:"running"`
+}
+
+// Combat describes a combat. Rolls are zero until they're revealed.
+type Combat struct {
+	AttackerMark   string `json:"attackerMark"`
+	DefenderMark   string `json:"defenderMark"`
+	AttackerUnit   string `json:"attackerUnit"`
+	DefenderUnit   string `json:"defenderUnit"`
+	AttackerRoll   int    `json:"attackerRoll"`
+	DefenderRoll   int    `json:"defenderRoll"`
+	AttackerBonus  int    `json:"attackerBonus,omitempty"` // Added to the attacker's roll by its class
+	DefenderBonus  int    `json:"defenderBonus,omitempty"` // Added to the defender's roll by its class
+	Winner         string `json:"winner"`                  // "attacker" or "defender", once resolved
+	Damage         int    `json:"damage"`
+	LoserMark      string `json:"loserMark"`
+	LoserUnit      string `json:"loserUnit,omitempty"`
+	AttackerRolled bool   `json:"attackerRolled,omitempty"`
+	DefenderRolled bool   `json:"defenderRolled,omitempty"`
+	RollDeadline   int64  `json:"rollDeadline,omitempty"` // Unix ms when the server rolls for whoever's next
+	Commitment     string `json:"commitment,omitempty"`   // Hash of both dice, sent up front
+	Salt           string `json:"salt,omitempty"`         // Revealed with the result
+}
+
+// Verify checks a resolved combat's revealed dice and salt against
+// commitment, the hash sent when the combat started. Keep the commitment
+// from "combat_start" rather than trusting the one sent with the result.
+func (c *Combat) Verify(commitment string) bool {
+	return gridwars.VerifyDice(commitment, c.AttackerRoll, c.DefenderRoll, c.Salt)
+}
+
+// RoomInfo is one room in the lobby listing
+type RoomInfo struct {
+	ID         string       `json:"id"`
+	Players    []PlayerInfo `json:"players"`
+	Spectators int          `json:"spectators"`
+	Queued     int          `json:"queued"`
+	Turn       string       `json:"turn"`
+	Winner     string       `json:"winner"`
+	Private    bool         `json:"private"`
+	Clock      string       `json:"clock"`
+	Rules      string       `json:"rules"`
+}
+
+// PlayerInfo is a seated player in a room listing
+type PlayerInfo struct {
+	Mark string `json:"mark"`
+	Name string `json:"name,omitempty"`
+	Away bool   `json:"away,omitempty"`
+	Bot  bool   `json:"bot,omitempty"`
+
+	Rating int `json:"rating,omitempty"` // Signed-in players only
+}
+
+// Account is a registered player's record
+type Account struct {
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+	Draws  int    `json:"draws"`
+	Key    string `json:"key,omitempty"` // "account": only when the name was just registered
+}
+
+// Proposal is a rematch or reset waiting on an answer
+type Proposal struct {
+	From string `json:"from"`
+	Kind string `json:"kind"` // "rematch" or "reset"
+}
+
+// QueueEntry is a spectator waiting for a seat
+type QueueEntry struct {
+	Name string `json:"name,omitempty"`
+}
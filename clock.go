@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time control modes
+const (
+	TimeControlNone  = "none"  // No timer - players can think forever
+	TimeControlTurn  = "turn"  // Fixed time for every turn
+	TimeControlClock = "clock" // Chess-style bank per player, plus an increment per move
+)
+
+// TimeControl configures how long players get to move
+type TimeControl struct {
+	Mode      string
+	PerTurn   time.Duration // turn mode: time allowed for each turn
+	Initial   time.Duration // clock mode: starting bank per player
+	Increment time.Duration // clock mode: added to a player's bank after each move
+	Forfeit   bool          // turn mode: forfeit on expiry instead of passing the turn
+}
+
+// ErrTimeControl is returned for a time control string that can't be parsed
+var ErrTimeControl = errors.New(`Invalid time control (use "none", "turn:30", "turn:30:forfeit" or "clock:300+5")`)
+
+// parseTimeControl reads a time control from a string like "none",
+// "turn:30" (30s per turn, pass on expiry), "turn:30:forfeit" or
+// "clock:300+5" (5 minute bank with a 5s increment). Numbers are seconds.
+func parseTimeControl(s string) (TimeControl, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == TimeControlNone {
+		return TimeControl{Mode: TimeControlNone}, nil
+	}
+
+	parts := strings.Split(s, ":")
+	switch {
+	case parts[0] == TimeControlTurn && (len(parts) == 2 || len(parts) == 3):
+		seconds, err := strconv.Atoi(parts[1])
+		if err != nil || seconds <= 0 {
+			return TimeControl{}, ErrTimeControl
+		}
+		tc := TimeControl{Mode: TimeControlTurn, PerTurn: time.Duration(seconds) * time.Second}
+		if len(parts) == 3 {
+			if parts[2] != "forfeit" && parts[2] != "pass" {
+				return TimeControl{}, ErrTimeControl
+			}
+			tc.Forfeit = parts[2] == "forfeit"
+		}
+		return tc, nil
+
+	case parts[0] == TimeControlClock && len(parts) == 2:
+		initial, increment, _ := strings.Cut(parts[1], "+")
+		initialSeconds, err := strconv.Atoi(initial)
+		if err != nil || initialSeconds <= 0 {
+			return TimeControl{}, ErrTimeControl
+		}
+		incrementSeconds := 0
+		if increment != "" {
+			incrementSeconds, err = strconv.Atoi(increment)
+			if err != nil || incrementSeconds < 0 {
+				return TimeControl{}, ErrTimeControl
+			}
+		}
+		return TimeControl{
+			Mode:      TimeControlClock,
+			Initial:   time.Duration(initialSeconds) * time.Second,
+			Increment: time.Duration(incrementSeconds) * time.Second,
+		}, nil
+	}
+	return TimeControl{}, ErrTimeControl
+}
+
+// String formats a time control the way parseTimeControl reads it
+func (tc TimeControl) String() string {
+	switch tc.Mode {
+	case TimeControlTurn:
+		s := fmt.Sprintf("turn:%d", int(tc.PerTurn.Seconds()))
+		if tc.Forfeit {
+			s += ":forfeit"
+		}
+		return s
+	case TimeControlClock:
+		return fmt.Sprintf("clock:%d+%d", int(tc.Initial.Seconds()), int(tc.Increment.Seconds()))
+	}
+	return TimeControlNone
+}
+
+// Clock tracks each player's remaining time. It's part of the game state so
+// every "state" message carries the latest remaining times.
+type Clock struct {
+	Control    string `json:"control"`    // Time control, e.g. "turn:30" or "clock:300+5"
+	RemainingX int64  `json:"remainingX"` // Milliseconds X has left
+	RemainingO int64  `json:"remainingO"` // Milliseconds O has left
+	Running    bool   `json:"running"`    // Whether the player to move is being timed
+
+	tc       TimeControl
+	lastTick time.Time
+}
+
+// newClock creates a clock for a time control, or nil if the game isn't timed
+func newClock(tc TimeControl) *Clock {
+	if tc.Mode != TimeControlTurn && tc.Mode != TimeControlClock {
+		return nil
+	}
+	c := &Clock{Control: tc.String(), tc: tc}
+	c.reset()
+	return c
+}
+
+// reset gives both players a full allowance and stops the clock
+func (c *Clock) reset() {
+	full := c.tc.PerTurn
+	if c.tc.Mode == TimeControlClock {
+		full = c.tc.Initial
+	}
+	c.RemainingX = full.Milliseconds()
+	c.RemainingO = full.Milliseconds()
+	c.Running = false
+	c.lastTick = time.Time{}
+}
+
+// remaining returns a pointer to a player's remaining time
+func (c *Clock) remaining(mark string) *int64 {
+	if mark == "X" {
+		return &c.RemainingX
+	}
+	return &c.RemainingO
+}
+
+// tick charges the elapsed time to the player on turn (if running) and
+// reports whether they've run out
+func (c *Clock) tick(now time.Time, turn string, running bool) bool {
+	if running && c.Running && !c.lastTick.IsZero() {
+		left := c.remaining(turn)
+		*left -= now.Sub(c.lastTick).Milliseconds()
+		if *left < 0 {
+			*left = 0
+		}
+	}
+	c.Running = running
+	c.lastTick = now
+	return running && *c.remaining(turn) <= 0
+}
+
+// turnEnded is called when mark finishes a turn: turn mode refills their
+// allowance, clock mode adds the increment
+func (c *Clock) turnEnded(mark string) {
+	left := c.remaining(mark)
+	if c.tc.Mode == TimeControlTurn {
+		*left = c.tc.PerTurn.Milliseconds()
+	} else {
+		*left += c.tc.Increment.Milliseconds()
+	}
+}
+
+// forfeitsOnExpiry reports whether running out of time loses the game
+// (clock mode always does - there's no time left to pass into)
+func (c *Clock) forfeitsOnExpiry() bool {
+	return c.tc.Mode == TimeControlClock || c.tc.Forfeit
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TimeControl
+	}{
+		{"", TimeControl{Mode: TimeControlNone}},
+		{"none", TimeControl{Mode: TimeControlNone}},
+		{"turn:30", TimeControl{Mode: TimeControlTurn, PerTurn: 30 * time.Second}},
+		{"turn:30:forfeit", TimeControl{Mode: TimeControlTurn, PerTurn: 30 * time.Second, Forfeit: true}},
+		{"clock:300+5", TimeControl{Mode: TimeControlClock, Initial: 300 * time.Second, Increment: 5 * time.Second}},
+		{"clock:60", TimeControl{Mode: TimeControlClock, Initial: 60 * time.Second}},
+	}
+
+	for _, test := range tests {
+		result, err := parseTimeControl(test.input)
+		if err != nil {
+			t.Errorf("parseTimeControl(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("parseTimeControl(%q) = %+v, expected %+v", test.input, result, test.expected)
+		}
+	}
+
+	for _, bad := range []string{"turn", "turn:0", "turn:abc", "turn:30:maybe", "clock:", "clock:60+x", "blitz"} {
+		if _, err := parseTimeControl(bad); err != ErrTimeControl {
+			t.Errorf("parseTimeControl(%q) expected ErrTimeControl, got %v", bad, err)
+		}
+	}
+}
+
+func TestClock_TurnModeRefillsAndExpires(t *testing.T) {
+	c := newClock(TimeControl{Mode: TimeControlTurn, PerTurn: 10 * time.Second})
+	start := time.Now()
+
+	c.tick(start, "X", true)
+	if c.tick(start.Add(4*time.Second), "X", true) {
+		t.Fatal("expired too early")
+	}
+	if c.RemainingX != 6000 {
+		t.Errorf("expected 6000ms left for X, got %d", c.RemainingX)
+	}
+
+	c.turnEnded("X")
+	if c.RemainingX != 10000 {
+		t.Errorf("expected X refilled to 10000ms, got %d", c.RemainingX)
+	}
+
+	if !c.tick(start.Add(15*time.Second), "O", true) {
+		t.Error("expected O to run out of time")
+	}
+	if c.RemainingO != 0 {
+		t.Errorf("expected O clamped to 0, got %d", c.RemainingO)
+	}
+}
+
+func TestClock_ClockModeAddsIncrementAndPauses(t *testing.T) {
+	c := newClock(TimeControl{Mode: TimeControlClock, Initial: 60 * time.Second, Increment: 2 * time.Second})
+	start := time.Now()
+
+	c.tick(start, "X", true)
+	c.tick(start.Add(5*time.Second), "X", true)
+	c.turnEnded("X")
+	if c.RemainingX != 57000 {
+		t.Errorf("expected 60s - 5s + 2s = 57000ms, got %d", c.RemainingX)
+	}
+
+	// Paused time isn't charged
+	c.tick(start.Add(6*time.Second), "O", false)
+	c.tick(start.Add(30*time.Second), "O", false)
+	if c.RemainingO != 60000 {
+		t.Errorf("expected paused O to keep 60000ms, got %d", c.RemainingO)
+	}
+	if !c.forfeitsOnExpiry() {
+		t.Error("clock mode should forfeit on expiry")
+	}
+}
+
+func TestNewClock_UntimedGameHasNoClock(t *testing.T) {
+	if newClock(TimeControl{Mode: TimeControlNone}) != nil {
+		t.Error("expected nil clock for untimed game")
+	}
+}
+
+// connectTestClient gives a client a real connection and returns the far
+// end, which reads what the server sends it
+func connectTestClient(t *testing.T, client *Client) *websocket.Conn {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	far, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { far.Close() })
+	client.Conn = <-conns
+	return far
+}
+
+func TestTickClock_BroadcastsWhenClockStarts(t *testing.T) {
+	r := newRoom("clock-start-test", RoomOptions{TimeControl: TimeControl{Mode: TimeControlTurn, PerTurn: 30 * time.Second}})
+	startTestCombat(r)
+	watcher := &Client{Role: "spectator"}
+	r.clients[watcher] = true
+	far := connectTestClient(t, watcher)
+
+	// The combat result goes out while the clock's still stopped for the dice
+	r.tickClock(time.Now())
+	r.checkRollDeadline(r.pendingCombat.Deadline.Add(time.Millisecond))
+	r.checkRollDeadline(r.pendingCombat.Deadline.Add(time.Millisecond))
+	r.tickClock(time.Now())
+
+	far.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg ServerMessage
+		if err := far.ReadJSON(&msg); err != nil {
+			t.Fatalf("expected a state with O's clock running, got %v", err)
+		}
+		if msg.Type == "state" && msg.Game != nil && msg.Game.Clock != nil && msg.Game.Clock.Running {
+			if msg.Game.Turn != "O" {
+				t.Errorf("expected O on turn, got %s", msg.Game.Turn)
+			}
+			return
+		}
+	}
+}
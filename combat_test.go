@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// placeUnit moves a unit straight to x,y, rules or no rules
+func placeUnit(g *Game, id string, x, y int) {
+	unit := g.Unit(id)
+	g.Board[unit.Y][unit.X] = ""
+	unit.X, unit.Y = x, y
+	g.Board[y][x] = id
+}
+
+// startTestCombat puts X and O next to each other with a pending combat
+// that X wins by 3
+func startTestCombat(r *Room) {
+	r.takeSeat(&Client{}, "X")
+	r.takeSeat(&Client{}, "O")
+	placeUnit(r.game, "O1", 1, gridwars.Classic.BoardSize-2)
+	r.game.Combat = &gridwars.Combat{
+		AttackerMark: "X",
+		DefenderMark: "O",
+		AttackerUnit: "X1",
+		DefenderUnit: "O1",
+		AttackerRoll: 5,
+		DefenderRoll: 2,
+		Winner:       "attacker",
+		LoserMark:    "O",
+		LoserUnit:    "O1",
+		Damage:       3,
+	}
+	r.pendingCombat = &PendingCombat{}
+	r.pendingCombat.startRollTimer(time.Now())
+}
+
+func TestCheckRollDeadline_RollsForIdleSides(t *testing.T) {
+	r := newRoom("roll-deadline-test", RoomOptions{})
+	startTestCombat(r)
+
+	// Before the deadline nothing happens
+	r.checkRollDeadline(time.Now())
+	if r.game.Combat.AttackerRolled {
+		t.Fatal("rolled for attacker before the deadline")
+	}
+
+	// Attacker idle past the deadline - server rolls for them, defender gets a fresh window
+	r.checkRollDeadline(r.pendingCombat.Deadline.Add(time.Millisecond))
+	if r.pendingCombat == nil || !r.game.Combat.AttackerRolled {
+		t.Fatal("expected attacker auto-rolled and combat still pending")
+	}
+
+	// Defender idle too - combat resolves
+	r.checkRollDeadline(r.pendingCombat.Deadline.Add(time.Millisecond))
+	if r.pendingCombat != nil {
+		t.Fatal("expected combat resolved after defender auto-roll")
+	}
+	if r.game.Unit("O1").HP != gridwars.Classic.MaxHP-3 {
+		t.Errorf("expected O to take 3 damage, got %d HP", r.game.Unit("O1").HP)
+	}
+	if r.game.Turn != "O" {
+		t.Errorf("expected turn to pass to O, got %s", r.game.Turn)
+	}
+}
+
+func TestFreeSeat_ResolvesCombatInvolvingThatPlayer(t *testing.T) {
+	r := newRoom("combat-leave-test", RoomOptions{})
+	startTestCombat(r)
+
+	r.freeSeat("O")
+
+	if r.pendingCombat != nil {
+		t.Fatal("expected pending combat cleared when defender left")
+	}
+	if r.game.Unit("O1").HP != gridwars.Classic.MaxHP-3 {
+		t.Errorf("expected pre-rolled outcome applied, O has %d HP", r.game.Unit("O1").HP)
+	}
+}
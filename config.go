@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// Server settings, overridable with environment variables (see loadConfig)
+var (
+	// seatGracePeriod is how long a disconnected player's seat is held for them
+	seatGracePeriod = 60 * time.Second
+
+	// savedSeatGracePeriod is how long seats are held in a saved room once
+	// nobody's connected to play, long enough to outlast the server being
+	// stopped while it's idle (SAVED_SEAT_GRACE_PERIOD, needs STATE_DIR)
+	savedSeatGracePeriod = 24 * time.Hour
+
+	// rollTimeout is how long a combatant has to click their dice before the server rolls for them
+	rollTimeout = 15 * time.Second
+
+	// pickTimeout is how long a player has to pick in a draft before the server picks for them
+	pickTimeout = 30 * time.Second
+
+	// defaultTimeControl applies to rooms created without one (TIME_CONTROL, e.g. "turn:30")
+	defaultTimeControl = TimeControl{Mode: TimeControlNone}
+
+	// defaultRules applies to rooms created without a preset (RULES, e.g. "blitz")
+	defaultRules = gridwars.Classic
+
+	// botTokens maps API tokens to the bot names they were issued for
+	// (BOT_TOKENS, e.g. "alphabot:s3cret,betabot:hunter2")
+	botTokens = map[string]string{}
+
+	// stateDir is where room snapshots are kept (STATE_DIR, empty = don't persist games)
+	stateDir = ""
+)
+
+// loadConfig reads settings from the environment, keeping defaults for anything unset or invalid
+func loadConfig() {
+	loadDuration("SEAT_GRACE_PERIOD", &seatGracePeriod)
+	loadDuration("SAVED_SEAT_GRACE_PERIOD", &savedSeatGracePeriod)
+	loadDuration("ROLL_TIMEOUT", &rollTimeout)
+	loadDuration("PICK_TIMEOUT", &pickTimeout)
+
+	if value := os.Getenv("TIME_CONTROL"); value != "" {
+		tc, err := parseTimeControl(value)
+		if err != nil {
+			fmt.Printf("Ignoring invalid TIME_CONTROL=%q\n", value)
+		} else {
+			defaultTimeControl = tc
+		}
+	}
+
+	if value := os.Getenv("RULES"); value != "" {
+		rules, err := parseRules(value)
+		if err != nil {
+			fmt.Printf("Ignoring invalid RULES=%q\n", value)
+		} else {
+			defaultRules = rules
+		}
+	}
+
+	if value := os.Getenv("BOT_TOKENS"); value != "" {
+		botTokens = parseBotTokens(value)
+	}
+
+	if value := os.Getenv("STATE_DIR"); value != "" {
+		stateDir = value
+	}
+}
+
+// loadDuration parses an env var like "90s" or "2m" into *d
+func loadDuration(name string, d *time.Duration) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		fmt.Printf("Ignoring invalid %s=%q\n", name, value)
+		return
+	}
+	*d = parsed
+}
@@ -0,0 +1,15 @@
+package main
+
+import "go-multiplayer/gridwars"
+
+// DiceSaltBytes is how many random bytes go into a combat's salt. It has to
+// be unguessable, or the commitment plus the attacker's revealed roll would
+// give away the defender's.
+const DiceSaltBytes = 16
+
+// commit salts a combat's pre-rolled dice and fills in the commitment
+// (see gridwars.DiceCommitment)
+func (p *PendingCombat) commit(combat *gridwars.Combat) {
+	p.Salt = randomID(DiceSaltBytes)
+	p.Commitment = gridwars.DiceCommitment(combat.AttackerRoll, combat.DefenderRoll, p.Salt)
+}
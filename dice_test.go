@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestAttack_CommitsToPreRolledDice(t *testing.T) {
+	r, x := newSeededCombatRoom("commit-test", 1)
+
+	r.handleAttackAction(x, "", 1, gridwars.Classic.BoardSize-2)
+
+	combat, pending := r.game.Combat, r.pendingCombat
+	if pending.Commitment == "" || len(pending.Salt) != DiceSaltBytes*2 {
+		t.Fatalf("expected a commitment and salt, got %q / %q", pending.Commitment, pending.Salt)
+	}
+	if !gridwars.VerifyDice(pending.Commitment, combat.AttackerRoll, combat.DefenderRoll, pending.Salt) {
+		t.Error("commitment doesn't match the pre-rolled dice")
+	}
+}
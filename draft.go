@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// DraftState is a draft as clients see it: the pool, both sides' picks and
+// whose pick it is, plus when the server picks for them
+type DraftState struct {
+	*gridwars.Draft
+	Deadline int64 `json:"deadline,omitempty"` // Unix ms when the server picks for whoever's next
+}
+
+// handlePick drafts a class for the client's squad
+func (r *Room) handlePick(client *Client, class string) {
+	if client.Role != "X" && client.Role != "O" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot pick"})
+		return
+	}
+
+	// The new pool (or the finished squads) go out as the rules report them
+	if err := r.apply(gridwars.Action{Type: gridwars.ActionPick, Mark: client.Role, Class: class}); err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+	}
+}
+
+// startPickTimer gives whoever's picking pickTimeout to choose. The timer
+// only runs while both seats are taken.
+func (r *Room) startPickTimer(now time.Time) {
+	r.pickDeadline = time.Time{}
+	if pickTimeout > 0 && r.seatFor("X") != nil && r.seatFor("O") != nil {
+		r.pickDeadline = now.Add(pickTimeout)
+	}
+}
+
+// checkPickDeadline starts the pick timer once both players are seated and
+// picks at random for whoever lets it run out
+func (r *Room) checkPickDeadline(now time.Time) {
+	draft := r.game.Draft
+	if draft == nil || r.game.Winner != "" || pickTimeout <= 0 || r.seatFor("X") == nil || r.seatFor("O") == nil {
+		r.pickDeadline = time.Time{}
+		return
+	}
+	if r.pickDeadline.IsZero() {
+		r.startPickTimer(now)
+		r.broadcastToAll(r.draftMessage())
+		return
+	}
+	if now.Before(r.pickDeadline) {
+		return
+	}
+
+	mark := draft.Turn
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: mark + " didn't pick in time - picking for them",
+	})
+	r.apply(gridwars.Action{Type: gridwars.ActionPick, Mark: mark})
+}
+
+// draftMessage describes the draft in progress
+func (r *Room) draftMessage() ServerMessage {
+	draft := &DraftState{Draft: r.game.Draft}
+	if !r.pickDeadline.IsZero() {
+		draft.Deadline = r.pickDeadline.UnixMilli()
+	}
+	return ServerMessage{Type: "draft_state", Draft: draft}
+}
+
+// sendDraft shows a (re)joining client the draft, if there is one
+func (r *Room) sendDraft(client *Client) {
+	if r.game.Draft != nil {
+		r.send(client, r.draftMessage())
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestCheckPickDeadline_PicksForIdlePlayer(t *testing.T) {
+	r := newRoom("draft-test", RoomOptions{Rules: gridwars.Skirmish})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+
+	// Nobody to draft against yet
+	r.checkPickDeadline(time.Now())
+	if !r.pickDeadline.IsZero() {
+		t.Fatal("expected no pick timer with one player seated")
+	}
+
+	r.takeSeat(o, "O")
+	r.checkPickDeadline(time.Now())
+	if r.pickDeadline.IsZero() || r.draftMessage().Draft.Deadline != r.pickDeadline.UnixMilli() {
+		t.Fatal("expected the pick timer to start once both are seated")
+	}
+
+	r.handlePick(&Client{Role: "spectator"}, "knight")
+	r.handlePick(o, "knight")
+	if r.game.Draft.Pool["knight"] != 2 {
+		t.Fatal("expected only X to be able to pick first")
+	}
+
+	// X idle past the deadline - server picks for them and O gets a fresh window
+	deadline := r.pickDeadline
+	r.checkPickDeadline(deadline.Add(time.Millisecond))
+	if len(r.game.Draft.Picks["X"]) != 1 || r.game.Draft.Turn != "O" || !r.pickDeadline.After(deadline) {
+		t.Fatalf("expected a pick made for X, got %+v", r.game.Draft)
+	}
+
+	for r.game.Draft != nil {
+		r.handlePick(r.seatFor(r.game.Draft.Turn).Client, "")
+	}
+	if len(r.game.Squad("X")) != gridwars.Skirmish.SquadSize || !r.pickDeadline.IsZero() || !r.clockRunning() {
+		t.Error("expected the squads to spawn and play to begin")
+	}
+}
+
+func TestDraft_EndsWithTheGame(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(r *Room, x, o *Client)
+	}{
+		{"draw", func(r *Room, x, o *Client) {
+			r.handleOfferDraw(x)
+			r.handleDrawAnswer(o, true)
+		}},
+		{"resignation", func(r *Room, x, o *Client) {
+			r.handleResign(o)
+		}},
+	}
+	for _, test := range tests {
+		r := newRoom("draft-end-test", RoomOptions{Rules: gridwars.Skirmish})
+		x, o := &Client{Role: "X"}, &Client{Role: "O"}
+		r.takeSeat(x, "X")
+		r.takeSeat(o, "O")
+		r.checkPickDeadline(time.Now())
+
+		test.end(r, x, o)
+		if r.game.Winner == "" || r.game.Draft != nil {
+			t.Fatalf("%s: expected the game over and the draft called off, got %+v", test.name, r.game.State)
+		}
+		r.checkPickDeadline(time.Now().Add(2 * pickTimeout))
+		if !r.pickDeadline.IsZero() {
+			t.Errorf("%s: expected the pick timer stopped", test.name)
+		}
+	}
+}
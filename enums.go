@@ -4,12 +4,36 @@ package main
 type ActionType string
 
 const (
-	ActionJoin    ActionType = "join"
-	ActionLeave   ActionType = "leave"
-	ActionMove    ActionType = "move"
-	ActionAttack  ActionType = "attack"
-	ActionRoll    ActionType = "roll"
-	ActionReset   ActionType = "reset"
-	ActionChat    ActionType = "chat"
-	ActionSetName ActionType = "setName"
+	ActionJoin           ActionType = "join"
+	ActionLeave          ActionType = "leave"
+	ActionDisconnect     ActionType = "disconnect"
+	ActionMove           ActionType = "move"
+	ActionAttack         ActionType = "attack"
+	ActionRoll           ActionType = "roll"
+	ActionPick           ActionType = "pick"
+	ActionReset          ActionType = "reset" // Same as rematch (kept for older clients)
+	ActionRematch        ActionType = "rematch"
+	ActionAcceptRematch  ActionType = "acceptRematch"
+	ActionDeclineRematch ActionType = "declineRematch"
+	ActionResign         ActionType = "resign"
+	ActionOfferDraw      ActionType = "offerDraw"
+	ActionAcceptDraw     ActionType = "acceptDraw"
+	ActionDeclineDraw    ActionType = "declineDraw"
+	ActionJoinQueue      ActionType = "joinQueue"
+	ActionLeaveQueue     ActionType = "leaveQueue"
+	ActionChat           ActionType = "chat"
+	ActionSetName        ActionType = "setName"
+	ActionAddBot         ActionType = "addBot"
+	ActionSignIn         ActionType = "signIn" // Handled by the lobby outside a room
+)
+
+// Lobby actions are handled by the connection itself rather than a room
+const (
+	ActionListRooms   ActionType = "listRooms"
+	ActionCreateRoom  ActionType = "createRoom"
+	ActionJoinRoom    ActionType = "joinRoom"
+	ActionLeaveRoom   ActionType = "leaveRoom"
+	ActionReplay      ActionType = "replay"
+	ActionFindMatch   ActionType = "findMatch"
+	ActionCancelMatch ActionType = "cancelMatch"
 )
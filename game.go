@@ -1,62 +1,91 @@
 package main
 
-import "github.com/gorilla/websocket"
+import (
+	"time"
 
-const BoardSize = 9
-const MaxHP = 10
-
-// Unit represents a player's unit on the board
-type Unit struct {
-	X           int  `json:"x"`
-	Y           int  `json:"y"`
-	HP          int  `json:"hp"`
-	MaxHP       int  `json:"maxHp"`
-	AttackBoost bool `json:"attackBoost"` // Next attack bypasses dice, deals 6 damage
-}
-
-// PowerUp represents a collectible on the board
-type PowerUp struct {
-	Type string `json:"type"` // "hp" or "attack"
-	X    int    `json:"x"`
-	Y    int    `json:"y"`
-}
+	"go-multiplayer/gridwars"
+)
 
 // CombatResult holds the details of a combat exchange for animation
 type CombatResult struct {
 	AttackerMark   string `json:"attackerMark"`             // "X" or "O"
 	DefenderMark   string `json:"defenderMark"`             // "X" or "O"
+	AttackerUnit   string `json:"attackerUnit"`             // ID of the attacking unit
+	DefenderUnit   string `json:"defenderUnit"`             // ID of the unit attacked
 	AttackerRoll   int    `json:"attackerRoll"`             // 1-6
 	DefenderRoll   int    `json:"defenderRoll"`             // 1-6
+	AttackerBonus  int    `json:"attackerBonus,omitempty"`  // Added to the attacker's roll by its class
+	DefenderBonus  int    `json:"defenderBonus,omitempty"`  // Added to the defender's roll by its class
 	Winner         string `json:"winner"`                   // "attacker" or "defender"
 	Damage         int    `json:"damage"`                   // Damage dealt to loser
 	LoserMark      string `json:"loserMark"`                // Who took damage ("X" or "O")
+	LoserUnit      string `json:"loserUnit,omitempty"`      // ID of the unit that took it
 	AttackerRolled bool   `json:"attackerRolled,omitempty"` // Has attacker clicked their dice?
 	DefenderRolled bool   `json:"defenderRolled,omitempty"` // Has defender clicked their dice?
+	RollDeadline   int64  `json:"rollDeadline,omitempty"`   // Unix ms when the server rolls for whoever's next
+	Commitment     string `json:"commitment,omitempty"`     // Hash of both rolls and the salt, published up front
+	Salt           string `json:"salt,omitempty"`           // Revealed with the result so the commitment can be checked
+}
+
+// newCombatResult copies a combat into a message. The dice are left out
+// unless reveal is set - the server knows them long before the players do.
+func newCombatResult(c *gridwars.Combat, reveal bool) *CombatResult {
+	result := &CombatResult{
+		AttackerMark:   c.AttackerMark,
+		DefenderMark:   c.DefenderMark,
+		AttackerUnit:   c.AttackerUnit,
+		DefenderUnit:   c.DefenderUnit,
+		AttackerBonus:  c.AttackerBonus,
+		DefenderBonus:  c.DefenderBonus,
+		AttackerRolled: c.AttackerRolled,
+		DefenderRolled: c.DefenderRolled,
+	}
+	if c.AttackerRolled || reveal {
+		result.AttackerRoll = c.AttackerRoll
+	}
+	if reveal {
+		result.DefenderRoll = c.DefenderRoll
+		result.Winner = c.Winner
+		result.Damage = c.Damage
+		result.LoserMark = c.LoserMark
+		result.LoserUnit = c.LoserUnit
+	}
+	return result
 }
 
-// PendingCombat tracks an in-progress combat waiting for both players to roll
+// PendingCombat is the server's side of a combat waiting for both players to
+// roll. The dice themselves are in the game state.
 type PendingCombat struct {
-	Combat   *CombatResult
-	Attacker *Unit
-	Defender *Unit
+	Deadline   time.Time // When the server rolls for the idle side (zero = never)
+	Commitment string    // Published hash of the dice
+	Salt       string    // Kept secret until the combat resolves
 }
 
-// Game represents the Grid Wars game state
-type Game struct {
-	Board    [BoardSize][BoardSize]string `json:"board"`    // "", "X", or "O"
-	Turn     string                       `json:"turn"`     // "X" or "O"
-	Winner   string                       `json:"winner"`   // "", "X", or "O"
-	PlayerX  *Player                      `json:"-"`        // - means don't include in JSON
-	PlayerO  *Player                      `json:"-"`
-	UnitX    *Unit                        `json:"unitX"`
-	UnitO    *Unit                        `json:"unitO"`
-	PowerUps []PowerUp                    `json:"powerUps"` // Active power-ups on board
+// startRollTimer gives the next combatant rollTimeout to click their dice
+func (p *PendingCombat) startRollTimer(now time.Time) {
+	p.Deadline = time.Time{}
+	if rollTimeout > 0 {
+		p.Deadline = now.Add(rollTimeout)
+	}
 }
 
-// Player represents a connected player
-type Player struct {
-	Conn *websocket.Conn
-	Mark string // "X" or "O"
+// deadlineMillis returns the roll deadline as Unix milliseconds for clients
+func (p *PendingCombat) deadlineMillis() int64 {
+	if p.Deadline.IsZero() {
+		return 0
+	}
+	return p.Deadline.UnixMilli()
+}
+
+// Game represents the Grid Wars game state: the rules' state plus what the
+// server tracks around it
+type Game struct {
+	ID string `json:"id"` // Identifies this game's replay
+	gridwars.State
+	DrawOffer string `json:"drawOffer,omitempty"` // Mark of the player offering a draw
+	Clock     *Clock `json:"clock,omitempty"`     // Remaining time (timed games only)
+
+	Accounts map[string]string `json:"accounts,omitempty"` // Signed-in player by mark - rated if both seats have one
 }
 
 // ClientMessage is what the browser sends to us
@@ -64,52 +93,51 @@ type ClientMessage struct {
 	Type    string `json:"type"`    // "move", "chat", "reset", "setName"
 	X       int    `json:"x"`       // 0, 1, or 2
 	Y       int    `json:"y"`       // 0, 1, or 2
+	Unit    string `json:"unit"`    // move/attack: ID of the unit acting (optional with one unit left)
+	Class   string `json:"class"`   // pick: class to draft ("" for a random one of those left)
 	Message string `json:"message"` // Chat message text
 	Name    string `json:"name"`    // Display name
+	Room    string `json:"room"`    // Room ID for createRoom/joinRoom
+	Private bool   `json:"private"` // createRoom: make the room invite-only
+	Code    string `json:"code"`    // joinRoom: invite code; createRoom: optional password
+	Token   string `json:"token"`   // joinRoom: session token to resume a held seat
+	GameID  string `json:"gameId"`  // replay: game to replay
+	Level   string `json:"level"`   // addBot: "easy", "medium" or "hard"
+	Key     string `json:"key"`     // signIn: account key (empty to register the name)
+
+	TimeControl string `json:"timeControl"` // createRoom: e.g. "turn:30", "clock:300+5"
+	Rules       string `json:"rules"`       // createRoom: rule preset, e.g. "blitz"
 }
 
 // ServerMessage is what we send to the browser
 type ServerMessage struct {
-	Type    string        `json:"type"`              // "state", "error", "assigned", "chat", "combat"
-	Game    *Game         `json:"game,omitempty"`    // Current game state
-	Mark    string        `json:"mark,omitempty"`    // "X", "O", or "spectator"
+	Type    string        `json:"type"`           // "state", "error", "assigned", "chat", "combat"
+	Game    *Game         `json:"game,omitempty"` // Current game state
+	Mark    string        `json:"mark,omitempty"` // "X", "O", or "spectator"
 	Error   string        `json:"error,omitempty"`
 	From    string        `json:"from,omitempty"`    // Role: "X", "O", "spectator", "system"
 	Name    string        `json:"name,omitempty"`    // Display name (optional)
 	Message string        `json:"message,omitempty"` // Chat message text
 	Combat  *CombatResult `json:"combat,omitempty"`  // Combat result for animation
-}
+	Room    string        `json:"room,omitempty"`    // Room ID the client is in
+	Rooms   []RoomInfo    `json:"rooms,omitempty"`   // Lobby listing for "rooms"
+	Code    string        `json:"code,omitempty"`    // Invite code for a private room ("invite")
+	Token   string        `json:"token,omitempty"`   // Session token for resuming a seat ("assigned")
 
-// Global game state - only touched by game manager goroutine, no mutex needed!
-var game = newGame()
+	Proposal *Proposal    `json:"proposal,omitempty"` // Rematch/reset proposal ("rematch_proposed", "rematch_declined")
+	Queue    []QueueEntry `json:"queue,omitempty"`    // Spectators waiting for a seat, in order ("queue")
+	Position int          `json:"position,omitempty"` // This client's place in the queue, 0 if not queued ("queue")
+	GameID   string       `json:"gameId,omitempty"`   // Game being replayed ("replay")
+	Event    *Event       `json:"event,omitempty"`    // One step of a replay ("replay")
+	Bot      bool         `json:"bot,omitempty"`      // This client is a bot ("assigned", "matched")
+	Account  *AccountInfo `json:"account,omitempty"`  // Account signed in to, with its key if just registered ("account")
 
-// Pending combat - set when combat starts, cleared when both roll
-var pendingCombat *PendingCombat
-
-// newGame creates a fresh game with units initialized
-func newGame() *Game {
-	g := &Game{Turn: "X"}
-	g.initializeUnits()
-	return g
-}
-
-// initializeUnits spawns units in opposite corners
-func (g *Game) initializeUnits() {
-	// X spawns bottom-left (0, 8), O spawns top-right (8, 0)
-	g.UnitX = &Unit{X: 0, Y: BoardSize - 1, HP: MaxHP, MaxHP: MaxHP}
-	g.UnitO = &Unit{X: BoardSize - 1, Y: 0, HP: MaxHP, MaxHP: MaxHP}
-	g.Board[g.UnitX.Y][g.UnitX.X] = "X"
-	g.Board[g.UnitO.Y][g.UnitO.X] = "O"
+	Draft *DraftState       `json:"draft,omitempty"` // Pool, picks and pick deadline ("draft_state")
+	Hints *gridwars.Hints   `json:"hints,omitempty"` // Legal moves and attack targets for the player to move (with game updates)
+	Legal []gridwars.Action `json:"legal,omitempty"` // What an API bot can do next (with game and combat updates)
 }
 
-// checkWinner checks if a unit has been eliminated
-func (g *Game) checkWinner() {
-	if g.UnitX != nil && g.UnitX.HP <= 0 {
-		g.Winner = "O"
-		return
-	}
-	if g.UnitO != nil && g.UnitO.HP <= 0 {
-		g.Winner = "X"
-		return
-	}
+// newGame creates a fresh game played by rules, with units initialized
+func newGame(rules gridwars.Rules) *Game {
+	return &Game{State: gridwars.NewState(rules)}
 }
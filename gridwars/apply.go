@@ -0,0 +1,354 @@
+package gridwars
+
+// ActionType is something a player can do on their turn
+type ActionType string
+
+const (
+	ActionMove   ActionType = "move"   // Move Unit to X,Y
+	ActionAttack ActionType = "attack" // Attack the enemy unit at X,Y with Unit
+	ActionRoll   ActionType = "roll"   // Reveal your die in a pending combat
+	ActionPick   ActionType = "pick"   // Pick Class for your squad in the draft
+	ActionPass   ActionType = "pass"   // Give up the rest of your turn (ran out of time)
+	ActionResign ActionType = "resign" // Concede the game
+)
+
+// Action is one player's move, in terms of the rules
+type Action struct {
+	Type  ActionType `json:"type"`
+	Mark  string     `json:"mark"`           // Player acting, "X" or "O"
+	Unit  string     `json:"unit,omitempty"` // ID of the unit moving or attacking (optional with one unit left)
+	X     int        `json:"x"`              // Target square for moves and attacks
+	Y     int        `json:"y"`
+	Class string     `json:"class,omitempty"` // Class to pick in the draft ("" for a random one of those left)
+}
+
+// EventType says what an Event describes
+type EventType string
+
+const (
+	EventMoved          EventType = "moved"            // Unit moved to X,Y
+	EventPickedUp       EventType = "picked_up"        // Unit collected PowerUp
+	EventPowerUpSpawned EventType = "power_up_spawned" // PowerUp appeared
+	EventAttacked       EventType = "attacked"         // Mark's Unit attacked the unit at X,Y
+	EventDiceRolled     EventType = "dice_rolled"      // Both dice rolled in secret (Combat)
+	EventRolled         EventType = "rolled"           // Mark revealed their die (Roll)
+	EventCombatResolved EventType = "combat_resolved"  // Combat's damage applied
+	EventTurnEnded      EventType = "turn_ended"       // Mark's turn is over
+	EventGameOver       EventType = "game_over"        // Winner decided, for Result
+	EventPicked         EventType = "picked"           // Mark drafted Class
+	EventDraftEnded     EventType = "draft_ended"      // Both squads are full and on the board
+)
+
+// Event is one thing that happened while applying an action, in order
+type Event struct {
+	Type    EventType
+	Mark    string
+	Unit    string // ID of the unit acting, for moves, pick-ups and attacks
+	Class   string // Class picked in the draft
+	Random  bool   // The class was drawn at random (from a pick with no class)
+	X       int
+	Y       int
+	Roll    int
+	PowerUp *PowerUp
+	Combat  *Combat // Copy of the combat as it stood
+	Winner  string
+	Result  string
+}
+
+// Rand is the source of all randomness in a game. Pass the same seeded
+// source to replay a game exactly.
+type Rand interface {
+	IntN(n int) int
+}
+
+// RuleError is an action the rules don't allow. The text is fit to show players.
+type RuleError string
+
+func (e RuleError) Error() string { return string(e) }
+
+// Reasons Apply rejects an action
+const (
+	ErrNotAPlayer       RuleError = "Only X and O can play"
+	ErrNotYourTurn      RuleError = "Not your turn"
+	ErrGameOver         RuleError = "Game is over"
+	ErrChooseUnit       RuleError = "Choose which unit to use"
+	ErrNotYourUnit      RuleError = "That's not one of your units"
+	ErrTooFar           RuleError = "Too far to move there"
+	ErrOutOfBounds      RuleError = "Out of bounds"
+	ErrOccupied         RuleError = "Square occupied"
+	ErrCombatInProgress RuleError = "Combat already in progress"
+	ErrNoEnemy          RuleError = "No enemy at that position"
+	ErrEnemyNotInRange  RuleError = "Enemy not in range"
+	ErrNoCombat         RuleError = "No combat in progress"
+	ErrNotInCombat      RuleError = "You are not in this combat"
+	ErrAttackerFirst    RuleError = "Wait for attacker to roll first"
+	ErrAlreadyRolled    RuleError = "Already rolled"
+	ErrUnknownAction    RuleError = "Unknown action"
+	ErrDrafting         RuleError = "Squads are still being picked"
+	ErrNoDraft          RuleError = "There's no draft going on"
+	ErrNotInPool        RuleError = "None of those left to pick"
+	ErrWall             RuleError = "Can't stand on a wall"
+	ErrBlocked          RuleError = "The way there is blocked"
+	ErrNoLineOfSight    RuleError = "A wall is in the way"
+)
+
+// Apply plays an action against a state and returns the resulting state and
+// what happened along the way. s itself is never modified; on error the
+// returned state is s unchanged.
+func Apply(s State, a Action, rng Rand) (State, []Event, error) {
+	if a.Mark != "X" && a.Mark != "O" {
+		return s, nil, ErrNotAPlayer
+	}
+
+	g := &game{State: s.Clone(), rng: rng}
+	var err error
+	switch a.Type {
+	case ActionMove:
+		err = g.move(a.Mark, a.Unit, a.X, a.Y)
+	case ActionAttack:
+		err = g.attack(a.Mark, a.Unit, a.X, a.Y)
+	case ActionRoll:
+		err = g.roll(a.Mark)
+	case ActionPick:
+		err = g.pick(a.Mark, a.Class)
+	case ActionPass:
+		err = g.pass(a.Mark)
+	case ActionResign:
+		err = g.resign(a.Mark)
+	default:
+		err = ErrUnknownAction
+	}
+	if err != nil {
+		return s, nil, err
+	}
+	return g.State, g.events, nil
+}
+
+// game is a state being changed by one Apply, collecting events as it goes
+type game struct {
+	State
+	rng    Rand
+	events []Event
+}
+
+func (g *game) emit(e Event) {
+	if e.Combat != nil {
+		combat := *e.Combat
+		e.Combat = &combat
+	}
+	g.events = append(g.events, e)
+}
+
+func (g *game) move(mark, id string, x, y int) error {
+	unit, err := g.actingUnit(mark, id)
+	if err != nil {
+		return err
+	}
+	if err := g.checkMove(unit, g.moveCosts(unit), x, y); err != nil {
+		return err
+	}
+
+	// Move the unit
+	g.Board[unit.Y][unit.X] = ""
+	unit.X = x
+	unit.Y = y
+	g.Board[y][x] = unit.ID
+	g.emit(Event{Type: EventMoved, Mark: mark, Unit: unit.ID, X: x, Y: y})
+
+	g.collectPowerUps(unit)
+	g.endTurn()
+	g.maybeSpawnPowerUp()
+	return nil
+}
+
+func (g *game) attack(mark, id string, x, y int) error {
+	attacker, err := g.actingUnit(mark, id)
+	if err != nil {
+		return err
+	}
+	if err := g.checkAttack(attacker, x, y); err != nil {
+		return err
+	}
+
+	defender := g.UnitAt(x, y)
+	g.emit(Event{Type: EventAttacked, Mark: mark, Unit: attacker.ID, X: x, Y: y})
+
+	combat := &Combat{AttackerMark: mark, DefenderMark: defender.Mark, AttackerUnit: attacker.ID, DefenderUnit: defender.ID}
+
+	// Attack boost - instant damage, no dice
+	if attacker.AttackBoost {
+		attacker.AttackBoost = false
+		combat.Boosted = true
+		combat.AttackerRoll = 6 // Shown as a max roll
+		combat.Winner = "attacker"
+		combat.LoserMark, combat.LoserUnit = combat.DefenderMark, combat.DefenderUnit
+		combat.Damage = g.Rules.BoostDamage
+		g.Combat = combat
+		g.resolveCombat()
+		return nil
+	}
+
+	// Roll both dice now, revealed later as each side rolls. Classes and cover add to them.
+	combat.AttackerRoll = g.rng.IntN(6) + 1
+	combat.DefenderRoll = g.rng.IntN(6) + 1
+	combat.AttackerBonus = g.ClassOf(attacker).AttackBonus
+	combat.DefenderBonus = g.DefenseBonus(defender)
+	attackTotal, defendTotal := combat.AttackerRoll+combat.AttackerBonus, combat.DefenderRoll+combat.DefenderBonus
+	if attackTotal >= defendTotal {
+		combat.Winner = "attacker"
+		combat.LoserMark, combat.LoserUnit = combat.DefenderMark, combat.DefenderUnit
+	} else {
+		combat.Winner = "defender"
+		combat.LoserMark, combat.LoserUnit = combat.AttackerMark, combat.AttackerUnit
+	}
+	combat.Damage = max(abs(attackTotal-defendTotal), 1)
+
+	g.Combat = combat
+	g.emit(Event{Type: EventDiceRolled, Combat: combat})
+	return nil
+}
+
+// roll reveals a combatant's die. The attacker goes first; once both are in
+// the combat is resolved.
+func (g *game) roll(mark string) error {
+	combat := g.Combat
+	if combat == nil {
+		return ErrNoCombat
+	}
+
+	switch mark {
+	case combat.AttackerMark:
+		if combat.AttackerRolled {
+			return ErrAlreadyRolled
+		}
+		combat.AttackerRolled = true
+		g.emit(Event{Type: EventRolled, Mark: mark, Roll: combat.AttackerRoll, Combat: combat})
+
+	case combat.DefenderMark:
+		if !combat.AttackerRolled {
+			return ErrAttackerFirst
+		}
+		if combat.DefenderRolled {
+			return ErrAlreadyRolled
+		}
+		combat.DefenderRolled = true
+		g.emit(Event{Type: EventRolled, Mark: mark, Roll: combat.DefenderRoll, Combat: combat})
+
+	default:
+		return ErrNotInCombat
+	}
+
+	if combat.AttackerRolled && combat.DefenderRolled {
+		g.resolveCombat()
+	}
+	return nil
+}
+
+// resolveCombat applies the pending combat's damage and ends the turn
+func (g *game) resolveCombat() {
+	combat := g.Combat
+	loser := g.Unit(combat.LoserUnit)
+	loser.HP = max(loser.HP-combat.Damage, 0)
+
+	g.Combat = nil
+	g.emit(Event{Type: EventCombatResolved, Mark: combat.AttackerMark, Combat: combat})
+
+	g.CheckWinner()
+
+	// Knocked out units leave the board
+	if !loser.Alive() {
+		g.Board[loser.Y][loser.X] = ""
+	}
+
+	if g.Winner != "" {
+		g.emit(Event{Type: EventGameOver, Winner: g.Winner, Result: g.Result})
+	} else {
+		g.endTurn()
+	}
+	g.maybeSpawnPowerUp()
+}
+
+// pass ends the turn without doing anything
+func (g *game) pass(mark string) error {
+	if err := g.checkTurn(mark); err != nil {
+		return err
+	}
+	g.endTurn()
+	return nil
+}
+
+func (g *game) resign(mark string) error {
+	if g.Winner != "" {
+		return ErrGameOver
+	}
+	if g.Combat != nil {
+		return ErrCombatInProgress
+	}
+	g.Finish(Other(mark), ResultResignation)
+	g.emit(Event{Type: EventGameOver, Winner: g.Winner, Result: g.Result})
+	return nil
+}
+
+// endTurn hands the turn to the other player
+func (g *game) endTurn() {
+	g.emit(Event{Type: EventTurnEnded, Mark: g.Turn})
+	g.Turn = Other(g.Turn)
+	g.Turns++
+}
+
+// maybeSpawnPowerUp has a chance to spawn a power-up on an empty square
+func (g *game) maybeSpawnPowerUp() {
+	if g.rng.IntN(100) >= g.Rules.PowerUpChance {
+		return
+	}
+	if len(g.PowerUps) >= g.Rules.MaxPowerUps {
+		return
+	}
+
+	// Find empty squares (not occupied by units, walls or other power-ups)
+	var emptySquares [][2]int
+	for y := range g.Board {
+		for x := range g.Board[y] {
+			if g.Board[y][x] == "" && g.TerrainAt(x, y) != Wall && !g.hasPowerUp(x, y) {
+				emptySquares = append(emptySquares, [2]int{x, y})
+			}
+		}
+	}
+	if len(emptySquares) == 0 {
+		return
+	}
+
+	pos := emptySquares[g.rng.IntN(len(emptySquares))]
+	powerUp := PowerUp{Type: "hp", X: pos[0], Y: pos[1]}
+	if g.rng.IntN(2) == 1 {
+		powerUp.Type = "attack"
+	}
+	g.PowerUps = append(g.PowerUps, powerUp)
+	g.emit(Event{Type: EventPowerUpSpawned, X: powerUp.X, Y: powerUp.Y, PowerUp: &powerUp})
+}
+
+func (g *game) hasPowerUp(x, y int) bool {
+	for _, p := range g.PowerUps {
+		if p.X == x && p.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+// collectPowerUps applies any power-up under a unit that just moved
+func (g *game) collectPowerUps(unit *Unit) {
+	for i := len(g.PowerUps) - 1; i >= 0; i-- {
+		p := g.PowerUps[i]
+		if p.X != unit.X || p.Y != unit.Y {
+			continue
+		}
+		if p.Type == "hp" {
+			unit.HP = min(unit.HP+g.Rules.HPBoost, unit.MaxHP)
+		} else if p.Type == "attack" {
+			unit.AttackBoost = true
+		}
+		g.PowerUps = append(g.PowerUps[:i], g.PowerUps[i+1:]...)
+		g.emit(Event{Type: EventPickedUp, Mark: unit.Mark, Unit: unit.ID, X: p.X, Y: p.Y, PowerUp: &p})
+	}
+}
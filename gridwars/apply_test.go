@@ -0,0 +1,476 @@
+package gridwars
+
+import (
+	"errors"
+	"testing"
+)
+
+// fixedRand hands out its numbers in order, then zeros
+type fixedRand []int
+
+func (r *fixedRand) IntN(n int) int {
+	if len(*r) == 0 {
+		return 0
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v % n
+}
+
+// noSpawn never spawns a power-up
+func noSpawn() *fixedRand {
+	return &fixedRand{99, 99, 99, 99}
+}
+
+// adjacentState puts O right next to X, with X to move
+func adjacentState() State {
+	s := NewState(Classic)
+	o := s.Unit("O1")
+	s.Board[o.Y][o.X] = ""
+	o.X, o.Y = 1, Classic.BoardSize-2
+	s.Board[o.Y][o.X] = o.ID
+	return s
+}
+
+func TestApply_MoveRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Action
+		err    error
+	}{
+		{"spectator", Action{Type: ActionMove, Mark: "spectator", X: 1, Y: Classic.BoardSize - 1}, ErrNotAPlayer},
+		{"wrong turn", Action{Type: ActionMove, Mark: "O", X: Classic.BoardSize - 2, Y: 0}, ErrNotYourTurn},
+		{"too far", Action{Type: ActionMove, Mark: "X", X: 4, Y: Classic.BoardSize - 1}, ErrTooFar},
+		{"stand still", Action{Type: ActionMove, Mark: "X", X: 0, Y: Classic.BoardSize - 1}, ErrTooFar},
+		{"off board", Action{Type: ActionMove, Mark: "X", X: -1, Y: Classic.BoardSize - 1}, ErrOutOfBounds},
+		{"unknown", Action{Type: "fly", Mark: "X"}, ErrUnknownAction},
+		{"ok", Action{Type: ActionMove, Mark: "X", X: 3, Y: Classic.BoardSize - 4}, nil},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(NewState(Classic), test.action, noSpawn())
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	s := adjacentState()
+	if _, _, err := Apply(s, Action{Type: ActionMove, Mark: "X", X: 1, Y: Classic.BoardSize - 2}, noSpawn()); err != ErrOccupied {
+		t.Errorf("expected ErrOccupied, got %v", err)
+	}
+}
+
+func TestApply_DoesNotChangeInput(t *testing.T) {
+	s := NewState(Classic)
+	s.PowerUps = []PowerUp{{Type: "hp", X: 1, Y: Classic.BoardSize - 1}}
+	s.Unit("X1").HP = 5
+
+	next, events, err := Apply(s, Action{Type: ActionMove, Mark: "X", X: 1, Y: Classic.BoardSize - 1}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Unit("X1").X != 0 || s.Unit("X1").HP != 5 || s.Board[Classic.BoardSize-1][1] != "" || len(s.PowerUps) != 1 || s.Turn != "X" {
+		t.Error("Apply modified the state it was given")
+	}
+	if next.Unit("X1").X != 1 || next.Unit("X1").HP != 5+Classic.HPBoost || len(next.PowerUps) != 0 || next.Turn != "O" || next.Turns != 1 {
+		t.Errorf("unexpected state after move: %+v", next)
+	}
+
+	want := []EventType{EventMoved, EventPickedUp, EventTurnEnded}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, typ := range want {
+		if events[i].Type != typ {
+			t.Errorf("event %d: expected %s, got %s", i, typ, events[i].Type)
+		}
+	}
+}
+
+func TestApply_Combat(t *testing.T) {
+	rng := &fixedRand{4, 1} // Rolls 5 against 2
+	s, events, err := Apply(adjacentState(), Action{Type: ActionAttack, Mark: "X", X: 1, Y: Classic.BoardSize - 2}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Combat == nil || s.Combat.AttackerRoll != 5 || s.Combat.DefenderRoll != 2 || s.Combat.Damage != 3 {
+		t.Fatalf("unexpected combat: %+v", s.Combat)
+	}
+	if events[len(events)-1].Type != EventDiceRolled {
+		t.Errorf("expected dice rolled, got %+v", events)
+	}
+
+	if _, _, err := Apply(s, Action{Type: ActionMove, Mark: "X", X: 2, Y: Classic.BoardSize - 1}, rng); err != ErrCombatInProgress {
+		t.Errorf("expected moves blocked during combat, got %v", err)
+	}
+	if _, _, err := Apply(s, Action{Type: ActionRoll, Mark: "O"}, rng); err != ErrAttackerFirst {
+		t.Errorf("expected ErrAttackerFirst, got %v", err)
+	}
+
+	s, _, err = Apply(s, Action{Type: ActionRoll, Mark: "X"}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Apply(s, Action{Type: ActionRoll, Mark: "X"}, rng); err != ErrAlreadyRolled {
+		t.Errorf("expected ErrAlreadyRolled, got %v", err)
+	}
+
+	*rng = fixedRand{99}
+	s, events, err = Apply(s, Action{Type: ActionRoll, Mark: "O"}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Combat != nil || s.Unit("O1").HP != Classic.MaxHP-3 || s.Turn != "O" {
+		t.Errorf("expected combat resolved and turn passed, got %+v", s)
+	}
+	if events[1].Type != EventCombatResolved || events[1].Combat.LoserMark != "O" {
+		t.Errorf("expected combat resolved event, got %+v", events)
+	}
+}
+
+func TestApply_BoostedAttackSkipsDice(t *testing.T) {
+	s := adjacentState()
+	s.Unit("X1").AttackBoost = true
+	s.Unit("O1").HP = Classic.BoostDamage
+
+	s, events, err := Apply(s, Action{Type: ActionAttack, Mark: "X", X: 1, Y: Classic.BoardSize - 2}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Unit("X1").AttackBoost || s.Combat != nil {
+		t.Error("expected boost used up and no combat pending")
+	}
+	if s.Winner != "X" || s.Result != ResultElimination || s.Board[Classic.BoardSize-2][1] != "" {
+		t.Errorf("expected O eliminated, got winner %q/%q", s.Winner, s.Result)
+	}
+	if last := events[len(events)-1]; last.Type != EventGameOver || last.Winner != "X" {
+		t.Errorf("expected game over last, got %+v", events)
+	}
+}
+
+func TestApply_PassAndResign(t *testing.T) {
+	s, events, err := Apply(NewState(Classic), Action{Type: ActionPass, Mark: "X"}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Turn != "O" || len(events) != 1 || events[0].Type != EventTurnEnded || events[0].Mark != "X" {
+		t.Errorf("expected X's turn to end, got %+v", events)
+	}
+
+	// Either player can resign, on turn or not
+	s, _, err = Apply(s, Action{Type: ActionResign, Mark: "X"}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Winner != "O" || s.Result != ResultResignation {
+		t.Errorf("expected O to win by resignation, got %q/%q", s.Winner, s.Result)
+	}
+	if _, _, err := Apply(s, Action{Type: ActionPass, Mark: "O"}, noSpawn()); err != ErrGameOver {
+		t.Errorf("expected ErrGameOver, got %v", err)
+	}
+}
+
+func TestApply_UsesRules(t *testing.T) {
+	move := Action{Type: ActionMove, Mark: "X", X: 4, Y: BigBoard.BoardSize - 1}
+	if _, _, err := Apply(NewState(BigBoard), move, noSpawn()); err != nil {
+		t.Errorf("big board allows moving 4, got %v", err)
+	}
+
+	// Classic only allows 3
+	move.Y = Classic.BoardSize - 1
+	if _, _, err := Apply(NewState(Classic), move, noSpawn()); err != ErrTooFar {
+		t.Errorf("expected ErrTooFar, got %v", err)
+	}
+
+	s := NewState(Blitz)
+	s.Unit("X1").AttackBoost = true
+	o := s.Unit("O1")
+	s.Board[o.Y][o.X] = ""
+	o.X, o.Y = 1, Blitz.BoardSize-2
+	s.Board[o.Y][o.X] = o.ID
+	s, _, err := Apply(s, Action{Type: ActionAttack, Mark: "X", X: 1, Y: Blitz.BoardSize - 2}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Unit("O1").HP != Blitz.MaxHP-Blitz.BoostDamage {
+		t.Errorf("expected boosted attack to deal %d, O has %d HP", Blitz.BoostDamage, s.Unit("O1").HP)
+	}
+}
+
+func TestLegalActions(t *testing.T) {
+	s := NewState(Classic)
+
+	// From the corner X can reach a 4x4 block, less the square it's on
+	if actions := s.LegalActions("X"); len(actions) != 15 {
+		t.Errorf("expected 15 moves from the corner, got %d", len(actions))
+	}
+	if actions := s.LegalActions("O"); len(actions) != 0 {
+		t.Errorf("expected nothing for O off turn, got %+v", actions)
+	}
+
+	s = adjacentState()
+	actions := s.LegalActions("X")
+	if last := actions[len(actions)-1]; last.Type != ActionAttack || last.X != 1 || last.Y != Classic.BoardSize-2 {
+		t.Errorf("expected the attack listed last, got %+v", last)
+	}
+	for _, a := range actions {
+		if _, _, err := Apply(s, a, noSpawn()); err != nil {
+			t.Errorf("listed action %+v is illegal: %v", a, err)
+		}
+	}
+
+	// During combat the only thing to do is roll, in order
+	s, _, _ = Apply(s, Action{Type: ActionAttack, Mark: "X", X: 1, Y: Classic.BoardSize - 2}, &fixedRand{4, 1})
+	if actions := s.LegalActions("X"); len(actions) != 1 || actions[0].Type != ActionRoll {
+		t.Errorf("expected the attacker to roll, got %+v", actions)
+	}
+	if actions := s.LegalActions("O"); len(actions) != 0 {
+		t.Errorf("defender rolls second, got %+v", actions)
+	}
+}
+
+func TestHints(t *testing.T) {
+	s := adjacentState()
+	hints := s.Hints()
+	if hints == nil || hints.Mark != "X" {
+		t.Fatalf("expected hints for X, got %+v", hints)
+	}
+	if len(hints.Targets) != 1 || hints.Targets[0] != (Square{"X1", 1, Classic.BoardSize - 2}) {
+		t.Errorf("expected O as the only target, got %+v", hints.Targets)
+	}
+	if len(hints.Moves)+len(hints.Targets) != len(s.LegalActions("X")) {
+		t.Errorf("expected a hint for every legal action, got %+v", hints)
+	}
+
+	s, _, _ = Apply(s, Action{Type: ActionAttack, Mark: "X", X: 1, Y: Classic.BoardSize - 2}, &fixedRand{4, 1})
+	if hints := s.Hints(); hints != nil {
+		t.Errorf("expected no hints while dice are pending, got %+v", hints)
+	}
+}
+
+func TestApply_Squads(t *testing.T) {
+	s := NewState(Squads)
+	if len(s.Squad("X")) != Squads.SquadSize || len(s.Squad("O")) != Squads.SquadSize {
+		t.Fatalf("expected %d units a side, got %+v", Squads.SquadSize, s.Units)
+	}
+
+	tests := []struct {
+		name   string
+		action Action
+		err    error
+	}{
+		{"no unit", Action{Type: ActionMove, Mark: "X", X: 2, Y: 6}, ErrChooseUnit},
+		{"enemy unit", Action{Type: ActionMove, Mark: "X", Unit: "O1", X: 2, Y: 6}, ErrNotYourUnit},
+		{"no such unit", Action{Type: ActionMove, Mark: "X", Unit: "X9", X: 2, Y: 6}, ErrNotYourUnit},
+		{"onto a teammate", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 1, Y: 8}, ErrOccupied},
+		{"attack a teammate", Action{Type: ActionAttack, Mark: "X", Unit: "X1", X: 1, Y: 8}, ErrNoEnemy},
+	}
+	for _, test := range tests {
+		if _, _, err := Apply(s, test.action, noSpawn()); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	next, events, err := Apply(s, Action{Type: ActionMove, Mark: "X", Unit: "X2", X: 2, Y: 6}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Board[8][1] != "" || next.Board[6][2] != "X2" || events[0].Unit != "X2" {
+		t.Errorf("expected X2 to move to 2,6, got board %v and events %+v", next.Board, events)
+	}
+	for _, a := range s.LegalActions("X") {
+		if a.Unit == "" {
+			t.Errorf("expected every move to name its unit, got %+v", a)
+		}
+	}
+
+	// Knocking out one unit leaves the rest of the squad playing
+	s.Unit("X1").AttackBoost = true
+	o1 := s.Unit("O1")
+	o1.HP = Squads.BoostDamage
+	s.Board[o1.Y][o1.X] = ""
+	o1.X, o1.Y = 1, 7
+	s.Board[o1.Y][o1.X] = o1.ID
+	s, _, err = Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X1", X: 1, Y: 7}, noSpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Winner != "" || s.Board[7][1] != "" || len(s.Squad("O")) != 2 {
+		t.Fatalf("expected O1 off the board and the game going on, got winner %q and squad %+v", s.Winner, s.Squad("O"))
+	}
+	if _, _, err := Apply(s, Action{Type: ActionMove, Mark: "O", Unit: "O1", X: 5, Y: 0}, noSpawn()); err != ErrNotYourUnit {
+		t.Errorf("expected a knocked out unit to stay out, got %v", err)
+	}
+
+	// The side with no units left loses
+	s.Unit("O2").HP, s.Unit("O3").HP = 0, 0
+	s.CheckWinner()
+	if s.Winner != "X" || s.Result != ResultElimination {
+		t.Errorf("expected X to win once O's squad is gone, got %q (%s)", s.Winner, s.Result)
+	}
+}
+
+func TestApply_Classes(t *testing.T) {
+	s := NewState(Tactics)
+	for i, id := range []string{"X1", "X2", "X3"} {
+		unit, class := s.Unit(id), Roster[i]
+		if unit.Class != class.Name || unit.HP != class.MaxHP || s.ClassOf(unit) != class {
+			t.Errorf("expected %s to be a fresh %s, got %+v", id, class.Name, unit)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		action Action
+		err    error
+	}{
+		{"knight moves 2", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 2, Y: 6}, nil},
+		{"knight can't move 3", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 3, Y: 5}, ErrTooFar},
+		{"scout moves 4", Action{Type: ActionMove, Mark: "X", Unit: "X3", X: 4, Y: 7}, nil},
+		{"scout can't move 5", Action{Type: ActionMove, Mark: "X", Unit: "X3", X: 5, Y: 7}, ErrTooFar},
+	}
+	for _, test := range tests {
+		if _, _, err := Apply(s, test.action, noSpawn()); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// The archer shoots from 3 squares away, where the knight can't reach
+	o := s.Unit("O1")
+	s.Board[o.Y][o.X] = ""
+	o.X, o.Y = 4, 8
+	s.Board[o.Y][o.X] = o.ID
+	if _, _, err := Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X1", X: 4, Y: 8}, noSpawn()); err != ErrEnemyNotInRange {
+		t.Errorf("expected the knight to be out of range, got %v", err)
+	}
+	s, _, err := Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X2", X: 4, Y: 8}, &fixedRand{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both roll 3, but the knight defends at +1
+	c := s.Combat
+	if c.AttackerBonus != Archer.AttackBonus || c.DefenderBonus != Knight.DefenseBonus {
+		t.Errorf("expected the classes' bonuses, got %+v", c)
+	}
+	if c.Winner != "defender" || c.LoserUnit != "X2" || c.Damage != 1 {
+		t.Errorf("expected the knight to win by 1, got %+v", c)
+	}
+}
+
+func TestApply_Draft(t *testing.T) {
+	s := NewState(Skirmish)
+	if s.Draft == nil || len(s.Units) != 0 || s.Hints() != nil {
+		t.Fatalf("expected a draft before any units, got %+v", s)
+	}
+	if _, _, err := Apply(s, Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 1, Y: 7}, noSpawn()); err != ErrDrafting {
+		t.Errorf("expected no moves during the draft, got %v", err)
+	}
+	if actions := s.LegalActions("X"); len(actions) != len(Roster) || actions[0].Type != ActionPick {
+		t.Errorf("expected a pick of every class, got %+v", actions)
+	}
+
+	// Snake order: X, O, O, X, X, O
+	picks := []struct {
+		mark, class string
+		err         error
+	}{
+		{"O", "knight", ErrNotYourTurn},
+		{"X", "knight", nil},
+		{"O", "knight", nil},
+		{"O", "archer", nil},
+		{"X", "knight", ErrNotInPool},
+		{"X", "dragon", ErrNotInPool},
+		{"X", "archer", nil},
+		{"X", "scout", nil},
+		{"O", "", nil}, // Only a scout left
+	}
+	for _, p := range picks {
+		next, events, err := Apply(s, Action{Type: ActionPick, Mark: p.mark, Class: p.class}, noSpawn())
+		if err != p.err {
+			t.Fatalf("%s picking %q: expected %v, got %v", p.mark, p.class, p.err, err)
+		}
+		if err == nil && events[0].Random != (p.class == "") {
+			t.Errorf("%s picking %q: expected the event to say whether it was random, got %+v", p.mark, p.class, events[0])
+		}
+		s = next
+	}
+
+	if s.Draft != nil || len(s.Units) != 2*Skirmish.SquadSize || s.Turn != "X" {
+		t.Fatalf("expected the draft to be over and X to move, got %+v", s)
+	}
+	for _, id := range []string{"X1", "O1"} {
+		if unit := s.Unit(id); unit.Class != "knight" || unit.HP != Knight.MaxHP {
+			t.Errorf("expected %s to be the knight picked first, got %+v", id, unit)
+		}
+	}
+	if s.Unit("O3").Class != "scout" || len(s.LegalActions("X")) == 0 {
+		t.Errorf("expected O's random pick to be the last scout and play to begin, got %+v", s.Units)
+	}
+}
+
+func TestApply_Terrain(t *testing.T) {
+	s := NewState(Wilds)
+	place := func(id string, x, y int) {
+		unit := s.Unit(id)
+		s.Board[unit.Y][unit.X] = ""
+		unit.X, unit.Y = x, y
+		s.Board[y][x] = id
+	}
+	if s.TerrainAt(3, 2) != Wall || s.TerrainAt(2, 5) != Forest || s.TerrainAt(5, 8) != Water {
+		t.Fatalf("expected the wilds map, got %v", s.Terrain)
+	}
+	if NewState(Classic).Terrain != nil {
+		t.Error("expected classic boards to stay plain")
+	}
+
+	// The knight moves 2
+	place("X1", 4, 8)
+	tests := []struct {
+		name   string
+		action Action
+		err    error
+	}{
+		{"over plain ground", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 4, Y: 6}, nil},
+		{"into water", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 5, Y: 8}, nil},
+		{"across water", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 6, Y: 7}, ErrBlocked},
+		{"through water", Action{Type: ActionMove, Mark: "X", Unit: "X1", X: 6, Y: 8}, ErrBlocked},
+		{"onto a wall", Action{Type: ActionMove, Mark: "X", Unit: "X2", X: 1, Y: 6}, ErrWall},
+	}
+	for _, test := range tests {
+		if _, _, err := Apply(s, test.action, noSpawn()); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+	for _, a := range s.LegalActions("X") {
+		if a.Type == ActionMove && (s.TerrainAt(a.X, a.Y) == Wall || a.Unit == "X1" && a.X == 6) {
+			t.Errorf("expected no way to %d,%d for %s", a.X, a.Y, a.Unit)
+		}
+	}
+
+	// Walls block the archer's shots, forest doesn't
+	place("X2", 4, 5)
+	place("O1", 4, 2)
+	if _, _, err := Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X2", X: 4, Y: 2}, noSpawn()); err != ErrNoLineOfSight {
+		t.Errorf("expected the wall at 4,4 to block the shot, got %v", err)
+	}
+	place("O1", 1, 5)
+	next, _, err := Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X2", X: 1, Y: 5}, &fixedRand{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Combat.DefenderBonus != Knight.DefenseBonus {
+		t.Errorf("expected no cover on plain ground, got %+v", next.Combat)
+	}
+
+	// Forest gives cover
+	place("O1", 2, 5)
+	next, _, err = Apply(s, Action{Type: ActionAttack, Mark: "X", Unit: "X2", X: 2, Y: 5}, &fixedRand{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Combat.DefenderBonus != Knight.DefenseBonus+ForestBonus || next.Combat.Winner != "defender" {
+		t.Errorf("expected the knight in the forest to defend at +%d, got %+v", Knight.DefenseBonus+ForestBonus, next.Combat)
+	}
+}
@@ -0,0 +1,24 @@
+package gridwars
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// DiceCommitment is the hash a server publishes when a combat starts, before
+// anyone rolls: hex(sha256("<attackerRoll>:<defenderRoll>:<salt>")). The
+// salt is revealed with the result so players can check the dice weren't
+// changed after the fact.
+func DiceCommitment(attackerRoll, defenderRoll int, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%s", attackerRoll, defenderRoll, salt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyDice checks revealed rolls and salt against the commitment that was
+// published when the combat started
+func VerifyDice(commitment string, attackerRoll, defenderRoll int, salt string) bool {
+	expected := DiceCommitment(attackerRoll, defenderRoll, salt)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(commitment)) == 1
+}
@@ -0,0 +1,17 @@
+package gridwars
+
+import "testing"
+
+func TestVerifyDice(t *testing.T) {
+	commitment := DiceCommitment(4, 2, "salt")
+
+	if !VerifyDice(commitment, 4, 2, "salt") {
+		t.Error("expected the committed rolls to verify")
+	}
+	if VerifyDice(commitment, 2, 4, "salt") {
+		t.Error("swapped rolls should not verify")
+	}
+	if VerifyDice(commitment, 4, 2, "pepper") {
+		t.Error("wrong salt should not verify")
+	}
+}
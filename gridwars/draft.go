@@ -0,0 +1,95 @@
+package gridwars
+
+// Draft is the pick phase before a game whose Rules have Draft set. Players
+// take turns picking classes from a shared pool in snake order (X, O, O, X,
+// X, O...) until both squads are full, then the units spawn and play begins.
+type Draft struct {
+	Pool  map[string]int      `json:"pool"`  // Classes left to pick: how many of each, by name
+	Picks map[string][]string `json:"picks"` // Classes each side has picked so far, by mark
+	Turn  string              `json:"turn"`  // Who picks next, "X" or "O"
+}
+
+// newDraft fills the pool with enough of every Roster class for both squads
+// to take the same one each
+func newDraft(rules Rules) *Draft {
+	n := rules.SquadSize
+	copies := (2*n + len(Roster) - 1) / len(Roster)
+	d := &Draft{Pool: make(map[string]int), Picks: map[string][]string{"X": {}, "O": {}}, Turn: "X"}
+	for _, class := range Roster {
+		d.Pool[class.Name] = copies
+	}
+	return d
+}
+
+// clone copies a draft so changing it leaves the original alone
+func (d *Draft) clone() *Draft {
+	c := &Draft{Pool: make(map[string]int), Picks: make(map[string][]string), Turn: d.Turn}
+	for name, left := range d.Pool {
+		c.Pool[name] = left
+	}
+	for mark, picks := range d.Picks {
+		c.Picks[mark] = append([]string{}, picks...)
+	}
+	return c
+}
+
+// picked is how many classes both sides have picked between them
+func (d *Draft) picked() int {
+	return len(d.Picks["X"]) + len(d.Picks["O"])
+}
+
+// pickTurn is who makes a draft's nth pick (counting from 0)
+func pickTurn(n int) string {
+	if n%4 == 0 || n%4 == 3 {
+		return "X"
+	}
+	return "O"
+}
+
+// Available lists the classes still in the pool, in Roster order
+func (d *Draft) Available() []string {
+	var names []string
+	for _, class := range Roster {
+		if d.Pool[class.Name] > 0 {
+			names = append(names, class.Name)
+		}
+	}
+	return names
+}
+
+// pick takes a class out of the pool for mark's squad. An empty class picks
+// one at random. Once both squads are full the draft is over and the units
+// spawn.
+func (g *game) pick(mark, class string) error {
+	d := g.Draft
+	if d == nil {
+		return ErrNoDraft
+	}
+	if g.Winner != "" {
+		return ErrGameOver
+	}
+	if d.Turn != mark {
+		return ErrNotYourTurn
+	}
+	random := class == ""
+	if random {
+		available := d.Available()
+		class = available[g.rng.IntN(len(available))]
+	}
+	if d.Pool[class] == 0 {
+		return ErrNotInPool
+	}
+
+	d.Pool[class]--
+	d.Picks[mark] = append(d.Picks[mark], class)
+	g.emit(Event{Type: EventPicked, Mark: mark, Class: class, Random: random})
+
+	if d.picked() < 2*g.Rules.SquadSize {
+		d.Turn = pickTurn(d.picked())
+		return nil
+	}
+	g.Draft = nil
+	g.initializeUnits(d.Picks)
+	g.emit(Event{Type: EventDraftEnded})
+	return nil
+}
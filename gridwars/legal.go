@@ -0,0 +1,156 @@
+package gridwars
+
+// checkTurn rejects actions from the player not on turn, while squads are
+// being drafted, after the game ended, or while a combat is waiting on dice
+func (s *State) checkTurn(mark string) error {
+	if s.Draft != nil {
+		return ErrDrafting
+	}
+	if s.Turn != mark {
+		return ErrNotYourTurn
+	}
+	if s.Winner != "" {
+		return ErrGameOver
+	}
+	if s.Combat != nil {
+		return ErrCombatInProgress
+	}
+	return nil
+}
+
+// actingUnit checks it's mark's turn and finds the unit they're acting
+// with: the one named by id, or their only unit left if id is empty
+func (s *State) actingUnit(mark, id string) (*Unit, error) {
+	if err := s.checkTurn(mark); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		squad := s.Squad(mark)
+		if len(squad) != 1 {
+			return nil, ErrChooseUnit
+		}
+		return squad[0], nil
+	}
+	unit := s.Unit(id)
+	if unit == nil || unit.Mark != mark || !unit.Alive() {
+		return nil, ErrNotYourUnit
+	}
+	return unit, nil
+}
+
+// checkMove says why unit can't move to x,y, or nil if it can. costs are
+// unit's moveCosts.
+func (s *State) checkMove(unit *Unit, costs [][]int, x, y int) error {
+	// Validate move is within range (Chebyshev distance)
+	distance := max(abs(x-unit.X), abs(y-unit.Y))
+	if distance == 0 || distance > s.ClassOf(unit).MoveRange {
+		return ErrTooFar
+	}
+	if !s.OnBoard(x, y) {
+		return ErrOutOfBounds
+	}
+	if s.Board[y][x] != "" {
+		return ErrOccupied
+	}
+	if s.TerrainAt(x, y) == Wall {
+		return ErrWall
+	}
+	// Walls and water can make it further than it looks
+	if costs != nil && costs[y][x] < 0 {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// checkAttack says why unit can't attack x,y, or nil if it can
+func (s *State) checkAttack(unit *Unit, x, y int) error {
+	defender := s.UnitAt(x, y)
+	if defender == nil || defender.Mark == unit.Mark {
+		return ErrNoEnemy
+	}
+	if max(abs(unit.X-defender.X), abs(unit.Y-defender.Y)) > s.ClassOf(unit).AttackRange {
+		return ErrEnemyNotInRange
+	}
+	if !s.lineOfSight(unit.X, unit.Y, x, y) {
+		return ErrNoLineOfSight
+	}
+	return nil
+}
+
+// LegalActions lists everything mark could do right now: picking each class
+// left during the draft, rolling their die if a combat is waiting on it,
+// otherwise for each of their units in turn, every move (in board order)
+// and then every attack. Moves and attacks always name the unit. It's empty
+// when it isn't mark's turn to act.
+func (s *State) LegalActions(mark string) []Action {
+	if d := s.Draft; d != nil {
+		if d.Turn != mark || s.Winner != "" {
+			return nil
+		}
+		var picks []Action
+		for _, class := range d.Available() {
+			picks = append(picks, Action{Type: ActionPick, Mark: mark, Class: class})
+		}
+		return picks
+	}
+	if c := s.Combat; c != nil {
+		if mark == c.AttackerMark && !c.AttackerRolled || mark == c.DefenderMark && c.AttackerRolled && !c.DefenderRolled {
+			return []Action{{Type: ActionRoll, Mark: mark}}
+		}
+		return nil
+	}
+	if s.checkTurn(mark) != nil {
+		return nil
+	}
+
+	var actions []Action
+	for _, unit := range s.Squad(mark) {
+		reach, costs := s.ClassOf(unit).MoveRange, s.moveCosts(unit)
+		for y := unit.Y - reach; y <= unit.Y+reach; y++ {
+			for x := unit.X - reach; x <= unit.X+reach; x++ {
+				if s.checkMove(unit, costs, x, y) == nil {
+					actions = append(actions, Action{Type: ActionMove, Mark: mark, Unit: unit.ID, X: x, Y: y})
+				}
+			}
+		}
+		for _, enemy := range s.Squad(Other(mark)) {
+			if s.checkAttack(unit, enemy.X, enemy.Y) == nil {
+				actions = append(actions, Action{Type: ActionAttack, Mark: mark, Unit: unit.ID, X: enemy.X, Y: enemy.Y})
+			}
+		}
+	}
+	return actions
+}
+
+// Square is a position on the board
+type Square struct {
+	Unit string `json:"unit"` // Unit that can move to or attack it
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// Hints are where the player to move can go and what they can attack, so
+// clients can highlight them without knowing the rules
+type Hints struct {
+	Mark    string   `json:"mark"`    // Player to move
+	Moves   []Square `json:"moves"`   // Empty squares in a unit's move range
+	Targets []Square `json:"targets"` // Enemies in a unit's attack range
+}
+
+// Hints lists the legal moves and attacks for the player whose turn it is,
+// or nil if nobody can act (the game is over or a combat is waiting on dice)
+func (s *State) Hints() *Hints {
+	if s.checkTurn(s.Turn) != nil {
+		return nil
+	}
+	hints := &Hints{Mark: s.Turn, Moves: []Square{}, Targets: []Square{}}
+	for _, a := range s.LegalActions(s.Turn) {
+		switch a.Type {
+		case ActionMove:
+			hints.Moves = append(hints.Moves, Square{a.Unit, a.X, a.Y})
+		case ActionAttack:
+			hints.Targets = append(hints.Targets, Square{a.Unit, a.X, a.Y})
+		}
+	}
+	return hints
+}
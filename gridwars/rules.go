@@ -0,0 +1,168 @@
+package gridwars
+
+// Rules are the numbers a game is played by. Every check Apply makes reads
+// them from the state, so a variant is just a different Rules value.
+type Rules struct {
+	Name          string `json:"name"`          // Preset this came from
+	BoardSize     int    `json:"boardSize"`     // Squares along each side
+	MaxHP         int    `json:"maxHp"`         // Starting (and highest) HP of each unit
+	MoveRange     int    `json:"moveRange"`     // How far a unit can move in one turn (Chebyshev distance)
+	AttackRange   int    `json:"attackRange"`   // How close the enemy has to be to attack
+	HPBoost       int    `json:"hpBoost"`       // HP an "hp" power-up restores
+	BoostDamage   int    `json:"boostDamage"`   // What a boosted attack deals, no dice involved
+	PowerUpChance int    `json:"powerUpChance"` // Percentage chance of a power-up spawning each turn
+	MaxPowerUps   int    `json:"maxPowerUps"`   // Most power-ups on the board at once
+	SquadSize     int    `json:"squadSize"`     // Units each player starts with
+	Classes       bool   `json:"classes"`       // Squads are drawn from Roster, with its stats in place of these
+	Draft         bool   `json:"draft"`         // Players pick their squads' classes before play (see Draft)
+	Terrain       bool   `json:"terrain"`       // The board has walls, forest and water on it (see Terrain)
+}
+
+// Classic is the original game
+var Classic = Rules{
+	Name:          "classic",
+	BoardSize:     9,
+	MaxHP:         10,
+	MoveRange:     3,
+	AttackRange:   1,
+	HPBoost:       3,
+	BoostDamage:   6,
+	PowerUpChance: 35,
+	MaxPowerUps:   3,
+	SquadSize:     1,
+}
+
+// Blitz is a short, swingy game on a small board
+var Blitz = Rules{
+	Name:          "blitz",
+	BoardSize:     7,
+	MaxHP:         6,
+	MoveRange:     3,
+	AttackRange:   1,
+	HPBoost:       2,
+	BoostDamage:   4,
+	PowerUpChance: 50,
+	MaxPowerUps:   2,
+	SquadSize:     1,
+}
+
+// BigBoard spreads the units out, with more power-ups to fight over
+var BigBoard = Rules{
+	Name:          "bigboard",
+	BoardSize:     13,
+	MaxHP:         12,
+	MoveRange:     4,
+	AttackRange:   1,
+	HPBoost:       3,
+	BoostDamage:   6,
+	PowerUpChance: 50,
+	MaxPowerUps:   5,
+	SquadSize:     1,
+}
+
+// Squads gives each player three weaker units to manoeuvre
+var Squads = Rules{
+	Name:          "squads",
+	BoardSize:     9,
+	MaxHP:         6,
+	MoveRange:     3,
+	AttackRange:   1,
+	HPBoost:       2,
+	BoostDamage:   4,
+	PowerUpChance: 35,
+	MaxPowerUps:   3,
+	SquadSize:     3,
+}
+
+// Tactics pits a knight, an archer and a scout against the same
+var Tactics = Rules{
+	Name:          "tactics",
+	BoardSize:     9,
+	MaxHP:         8,
+	MoveRange:     2,
+	AttackRange:   1,
+	HPBoost:       3,
+	BoostDamage:   5,
+	PowerUpChance: 35,
+	MaxPowerUps:   3,
+	SquadSize:     3,
+	Classes:       true,
+}
+
+// Skirmish has players draft their squads from a shared pool of classes
+var Skirmish = Rules{
+	Name:          "skirmish",
+	BoardSize:     9,
+	MaxHP:         8,
+	MoveRange:     2,
+	AttackRange:   1,
+	HPBoost:       3,
+	BoostDamage:   5,
+	PowerUpChance: 35,
+	MaxPowerUps:   3,
+	SquadSize:     3,
+	Classes:       true,
+	Draft:         true,
+}
+
+// Wilds is Tactics fought over walls, forest and water
+var Wilds = Rules{
+	Name:          "wilds",
+	BoardSize:     9,
+	MaxHP:         8,
+	MoveRange:     2,
+	AttackRange:   1,
+	HPBoost:       3,
+	BoostDamage:   5,
+	PowerUpChance: 35,
+	MaxPowerUps:   3,
+	SquadSize:     3,
+	Classes:       true,
+	Terrain:       true,
+}
+
+// Presets are the rule sets players can pick from, Classic first
+var Presets = []Rules{Classic, Blitz, BigBoard, Squads, Tactics, Skirmish, Wilds}
+
+// Preset looks up a rule set by name
+func Preset(name string) (Rules, bool) {
+	for _, rules := range Presets {
+		if rules.Name == name {
+			return rules, true
+		}
+	}
+	return Rules{}, false
+}
+
+// Class is a kind of unit, with its own stats. In games without classes every
+// unit gets the same ones, from the Rules.
+type Class struct {
+	Name         string `json:"name"`
+	MaxHP        int    `json:"maxHp"`
+	MoveRange    int    `json:"moveRange"`    // Chebyshev distance, like Rules.MoveRange
+	AttackRange  int    `json:"attackRange"`  // How close the enemy has to be
+	AttackBonus  int    `json:"attackBonus"`  // Added to its die when it attacks
+	DefenseBonus int    `json:"defenseBonus"` // Added to its die when it's attacked
+}
+
+// The unit classes
+var (
+	Knight = Class{Name: "knight", MaxHP: 12, MoveRange: 2, AttackRange: 1, AttackBonus: 1, DefenseBonus: 1}
+	Archer = Class{Name: "archer", MaxHP: 6, MoveRange: 2, AttackRange: 3, DefenseBonus: -1}
+	Scout  = Class{Name: "scout", MaxHP: 5, MoveRange: 4, AttackRange: 1, AttackBonus: -1}
+)
+
+// Roster is who makes up a squad when the rules use classes, in spawn order
+// (starting over for squads bigger than this)
+var Roster = []Class{Knight, Archer, Scout}
+
+// ClassOf returns a unit's class: one from Roster, or for a unit without a
+// class, stats taken from the Rules
+func (s *State) ClassOf(u *Unit) Class {
+	for _, class := range Roster {
+		if class.Name == u.Class {
+			return class
+		}
+	}
+	return Class{MaxHP: s.Rules.MaxHP, MoveRange: s.Rules.MoveRange, AttackRange: s.Rules.AttackRange}
+}
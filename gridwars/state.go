@@ -0,0 +1,239 @@
+// Package gridwars implements the rules of Grid Wars: two squads of units on
+// a square board moving, picking up power-ups and fighting with dice. It's pure game
+// logic - no networking, clocks or global state - so the server, bots and
+// tools can all drive it the same way through Apply.
+package gridwars
+
+import "fmt"
+
+// How a game ended, reported in State.Result
+const (
+	ResultElimination = "elimination" // Every unit on one side was knocked out
+	ResultResignation = "resignation" // A player resigned
+	ResultDraw        = "draw"        // Players agreed to a draw
+	ResultTimeout     = "timeout"     // A player ran out of time
+	ResultAbandoned   = "abandoned"   // A player left mid-game and didn't come back
+)
+
+// WinnerDraw is the Winner value for a drawn game
+const WinnerDraw = "draw"
+
+// Unit is one of a player's pieces on the board
+type Unit struct {
+	ID          string `json:"id"`              // e.g. "X2" - what the board holds where the unit stands
+	Mark        string `json:"mark"`            // Side it plays for, "X" or "O"
+	Class       string `json:"class,omitempty"` // Name of its Class, if the rules use them
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	HP          int    `json:"hp"` // Knocked out (and off the board) at zero
+	MaxHP       int    `json:"maxHp"`
+	AttackBoost bool   `json:"attackBoost"` // Next attack bypasses dice, deals Rules.BoostDamage
+}
+
+// Alive reports whether the unit is still in the game
+func (u *Unit) Alive() bool {
+	return u.HP > 0
+}
+
+// PowerUp represents a collectible on the board
+type PowerUp struct {
+	Type string `json:"type"` // "hp" or "attack"
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// Combat is an attack's dice and outcome. Both dice are rolled when the
+// attack is made; the combatants then reveal them one at a time.
+type Combat struct {
+	AttackerMark   string `json:"attackerMark"`             // "X" or "O"
+	DefenderMark   string `json:"defenderMark"`             // "X" or "O"
+	AttackerUnit   string `json:"attackerUnit"`             // ID of the attacking unit
+	DefenderUnit   string `json:"defenderUnit"`             // ID of the unit attacked
+	AttackerRoll   int    `json:"attackerRoll"`             // 1-6
+	DefenderRoll   int    `json:"defenderRoll"`             // 1-6
+	AttackerBonus  int    `json:"attackerBonus"`            // Added to the attacker's roll by its class
+	DefenderBonus  int    `json:"defenderBonus"`            // Added to the defender's roll by its class
+	Winner         string `json:"winner"`                   // "attacker" or "defender"
+	Damage         int    `json:"damage"`                   // Damage dealt to loser
+	LoserMark      string `json:"loserMark"`                // Who took damage ("X" or "O")
+	LoserUnit      string `json:"loserUnit"`                // ID of the unit that took it
+	AttackerRolled bool   `json:"attackerRolled,omitempty"` // Has attacker revealed their die?
+	DefenderRolled bool   `json:"defenderRolled,omitempty"` // Has defender revealed their die?
+	Boosted        bool   `json:"boosted,omitempty"`        // Attack boost - no dice, fixed damage
+}
+
+// State is everything the rules need to know about a game
+type State struct {
+	Rules    Rules       `json:"rules"`             // What the game is played by
+	Board    [][]string  `json:"board"`             // Board[y][x]: "" or the ID of the unit standing there
+	Turn     string      `json:"turn"`              // "X" or "O"
+	Winner   string      `json:"winner"`            // "", "X", "O", or "draw"
+	Result   string      `json:"result,omitempty"`  // How the game ended (Result* constants)
+	Turns    int         `json:"turns"`             // Turns completed so far
+	Units    []*Unit     `json:"units"`             // Every unit, X's then O's, knocked out ones included
+	PowerUps []PowerUp   `json:"powerUps"`          // Active power-ups on board
+	Combat   *Combat     `json:"-"`                 // Combat waiting on dice (its rolls are secret)
+	Draft    *Draft      `json:"draft,omitempty"`   // Squads still being picked (no units on the board yet)
+	Terrain  [][]Terrain `json:"terrain,omitempty"` // What each square is made of, [y][x] (nil = all plain)
+}
+
+// NewState creates a fresh game played by rules, with X to move
+func NewState(rules Rules) State {
+	s := State{Rules: rules, Turn: "X"}
+	s.Board = make([][]string, rules.BoardSize)
+	for y := range s.Board {
+		s.Board[y] = make([]string, rules.BoardSize)
+	}
+	s.Terrain = newTerrain(rules)
+	if rules.Draft {
+		s.Draft = newDraft(rules) // Units spawn once it's over
+	} else {
+		s.initializeUnits(nil)
+	}
+	return s
+}
+
+// initializeUnits spawns the squads in opposite corners, X bottom-left and
+// O top-right, filling the diagonals out from the corner. Units take the
+// classes picked for them in a draft, if there was one.
+func (s *State) initializeUnits(picks map[string][]string) {
+	size := s.Rules.BoardSize
+	spawns := spawnSquares(s.Rules.SquadSize)
+	for _, mark := range []string{"X", "O"} {
+		for i, spawn := range spawns {
+			unit := &Unit{ID: fmt.Sprintf("%s%d", mark, i+1), Mark: mark, X: spawn[0], Y: size - 1 - spawn[1]}
+			if i < len(picks[mark]) {
+				unit.Class = picks[mark][i]
+			} else if s.Rules.Classes {
+				unit.Class = Roster[i%len(Roster)].Name
+			}
+			unit.HP = s.ClassOf(unit).MaxHP
+			unit.MaxHP = unit.HP
+			if mark == "O" {
+				unit.X, unit.Y = size-1-spawn[0], spawn[1]
+			}
+			s.Units = append(s.Units, unit)
+			s.Board[unit.Y][unit.X] = unit.ID
+		}
+	}
+}
+
+// spawnSquares lists n squares as offsets from a corner: the corner, then
+// the diagonal next to it, and so on
+func spawnSquares(n int) [][2]int {
+	var squares [][2]int
+	for d := 0; len(squares) < n; d++ {
+		for dx := d; dx >= 0 && len(squares) < n; dx-- {
+			squares = append(squares, [2]int{dx, d - dx})
+		}
+	}
+	return squares
+}
+
+// Clone returns a deep copy that shares nothing with s
+func (s State) Clone() State {
+	board := make([][]string, len(s.Board))
+	for y, row := range s.Board {
+		board[y] = append([]string(nil), row...)
+	}
+	s.Board = board
+	if s.Units != nil {
+		units := make([]*Unit, len(s.Units))
+		for i, u := range s.Units {
+			unit := *u
+			units[i] = &unit
+		}
+		s.Units = units
+	}
+	if s.PowerUps != nil {
+		s.PowerUps = append([]PowerUp(nil), s.PowerUps...)
+	}
+	if s.Combat != nil {
+		combat := *s.Combat
+		s.Combat = &combat
+	}
+	if s.Draft != nil {
+		s.Draft = s.Draft.clone()
+	}
+	if s.Terrain != nil {
+		terrain := make([][]Terrain, len(s.Terrain))
+		for y, row := range s.Terrain {
+			terrain[y] = append([]Terrain(nil), row...)
+		}
+		s.Terrain = terrain
+	}
+	return s
+}
+
+// OnBoard reports whether x,y is a square on the board
+func (s *State) OnBoard(x, y int) bool {
+	return x >= 0 && x < s.Rules.BoardSize && y >= 0 && y < s.Rules.BoardSize
+}
+
+// Unit returns the unit with an ID, or nil if there isn't one
+func (s *State) Unit(id string) *Unit {
+	for _, unit := range s.Units {
+		if unit.ID == id {
+			return unit
+		}
+	}
+	return nil
+}
+
+// UnitAt returns the unit standing on x,y, or nil if the square is empty
+func (s *State) UnitAt(x, y int) *Unit {
+	if !s.OnBoard(x, y) || s.Board[y][x] == "" {
+		return nil
+	}
+	return s.Unit(s.Board[y][x])
+}
+
+// Squad returns mark's units that are still in the game
+func (s *State) Squad(mark string) []*Unit {
+	var squad []*Unit
+	for _, unit := range s.Units {
+		if unit.Mark == mark && unit.Alive() {
+			squad = append(squad, unit)
+		}
+	}
+	return squad
+}
+
+// Finish ends the game with a winner ("X", "O" or WinnerDraw) and the
+// reason. A draft still going is called off.
+func (s *State) Finish(winner, result string) {
+	s.Draft = nil
+	s.Winner = winner
+	s.Result = result
+}
+
+// CheckWinner ends the game once a side has no units left
+func (s *State) CheckWinner() {
+	if s.Draft != nil {
+		return // Nobody has units yet
+	}
+	if len(s.Squad("X")) == 0 {
+		s.Finish("O", ResultElimination)
+		return
+	}
+	if len(s.Squad("O")) == 0 {
+		s.Finish("X", ResultElimination)
+		return
+	}
+}
+
+// Other returns the opponent of "X" or "O"
+func Other(mark string) string {
+	if mark == "X" {
+		return "O"
+	}
+	return "X"
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package gridwars
+
+import "testing"
+
+func TestCheckWinner_XEliminated(t *testing.T) {
+	g := NewState(Classic)
+	g.Unit("X1").HP = 0
+
+	g.CheckWinner()
+
+	if g.Winner != "O" {
+		t.Errorf("expected winner O, got %s", g.Winner)
+	}
+}
+
+func TestCheckWinner_OEliminated(t *testing.T) {
+	g := NewState(Classic)
+	g.Unit("O1").HP = 0
+
+	g.CheckWinner()
+
+	if g.Winner != "X" {
+		t.Errorf("expected winner X, got %s", g.Winner)
+	}
+}
+
+func TestCheckWinner_NoWinnerYet(t *testing.T) {
+	g := NewState(Classic)
+
+	g.CheckWinner()
+
+	if g.Winner != "" {
+		t.Errorf("expected no winner, got %s", g.Winner)
+	}
+}
+
+func TestInitializeUnits(t *testing.T) {
+	g := NewState(Classic)
+
+	// X should spawn at bottom-left (0, 8)
+	if g.Unit("X1").X != 0 || g.Unit("X1").Y != Classic.BoardSize-1 {
+		t.Errorf("X unit at wrong position: got (%d, %d), expected (0, %d)", g.Unit("X1").X, g.Unit("X1").Y, Classic.BoardSize-1)
+	}
+
+	// O should spawn at top-right (8, 0)
+	if g.Unit("O1").X != Classic.BoardSize-1 || g.Unit("O1").Y != 0 {
+		t.Errorf("O unit at wrong position: got (%d, %d), expected (%d, 0)", g.Unit("O1").X, g.Unit("O1").Y, Classic.BoardSize-1)
+	}
+
+	// Both should start with Classic.MaxHP
+	if g.Unit("X1").HP != Classic.MaxHP {
+		t.Errorf("X unit should have %d HP, got %d", Classic.MaxHP, g.Unit("X1").HP)
+	}
+	if g.Unit("O1").HP != Classic.MaxHP {
+		t.Errorf("O unit should have %d HP, got %d", Classic.MaxHP, g.Unit("O1").HP)
+	}
+	if g.Unit("X1").MaxHP != Classic.MaxHP {
+		t.Errorf("X unit MaxHP should be %d, got %d", Classic.MaxHP, g.Unit("X1").MaxHP)
+	}
+
+	// Board should have units placed
+	if g.Board[g.Unit("X1").Y][g.Unit("X1").X] != "X1" {
+		t.Errorf("X not on board at its position")
+	}
+	if g.Board[g.Unit("O1").Y][g.Unit("O1").X] != "O1" {
+		t.Errorf("O not on board at its position")
+	}
+}
+
+func TestNewState(t *testing.T) {
+	g := NewState(Classic)
+
+	if g.Turn != "X" {
+		t.Errorf("expected X to start, got %s", g.Turn)
+	}
+	if g.Winner != "" {
+		t.Errorf("expected no winner at start, got %s", g.Winner)
+	}
+	if g.Unit("X1") == nil || g.Unit("O1") == nil {
+		t.Error("units should be initialized")
+	}
+}
+
+func TestAbs(t *testing.T) {
+	tests := []struct {
+		input    int
+		expected int
+	}{
+		{5, 5},
+		{-5, 5},
+		{0, 0},
+		{-1, 1},
+	}
+
+	for _, test := range tests {
+		result := abs(test.input)
+		if result != test.expected {
+			t.Errorf("abs(%d) = %d, expected %d", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestNewState_UsesRules(t *testing.T) {
+	s := NewState(Blitz)
+
+	if len(s.Board) != Blitz.BoardSize || len(s.Board[0]) != Blitz.BoardSize {
+		t.Fatalf("expected a %dx%d board", Blitz.BoardSize, Blitz.BoardSize)
+	}
+	if s.Unit("O1").X != Blitz.BoardSize-1 || s.Board[0][Blitz.BoardSize-1] != "O1" {
+		t.Error("O should start in the top-right corner")
+	}
+	if s.Unit("X1").HP != Blitz.MaxHP || s.Unit("X1").MaxHP != Blitz.MaxHP {
+		t.Errorf("expected %d HP, got %d", Blitz.MaxHP, s.Unit("X1").HP)
+	}
+}
+
+func TestPreset(t *testing.T) {
+	for _, rules := range Presets {
+		if found, ok := Preset(rules.Name); !ok || found != rules {
+			t.Errorf("preset %q not found", rules.Name)
+		}
+	}
+	if _, ok := Preset("chess"); ok {
+		t.Error("expected unknown preset to be rejected")
+	}
+}
+
+func TestInitializeUnits_Squad(t *testing.T) {
+	s := NewState(Squads)
+
+	want := map[string]Square{
+		"X1": {X: 0, Y: 8}, "X2": {X: 1, Y: 8}, "X3": {X: 0, Y: 7},
+		"O1": {X: 8, Y: 0}, "O2": {X: 7, Y: 0}, "O3": {X: 8, Y: 1},
+	}
+	if len(s.Units) != len(want) {
+		t.Fatalf("expected %d units, got %d", len(want), len(s.Units))
+	}
+	for id, at := range want {
+		unit := s.Unit(id)
+		if unit == nil || unit.X != at.X || unit.Y != at.Y || s.Board[at.Y][at.X] != id || unit.Mark != id[:1] {
+			t.Errorf("expected %s at %d,%d, got %+v", id, at.X, at.Y, unit)
+		}
+	}
+}
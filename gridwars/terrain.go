@@ -0,0 +1,155 @@
+package gridwars
+
+// Terrain is what a square is made of. Plain ground is "".
+type Terrain string
+
+const (
+	Plain  Terrain = ""
+	Wall   Terrain = "wall"   // Can't be entered, moved through or shot through
+	Forest Terrain = "forest" // Cover: adds ForestBonus to the defense die of a unit standing in it
+	Water  Terrain = "water"  // Costs WaterCost of a unit's move to wade into
+)
+
+// What terrain is worth
+const (
+	ForestBonus = 1
+	WaterCost   = 2
+)
+
+// wildsMap is the board for Rules with Terrain set: # wall, f forest, ~ water.
+// It reads the same turned upside down, so neither side has the better
+// ground, and leaves the spawn corners clear.
+var wildsMap = []string{
+	"..~~.....",
+	"...~..f..",
+	"f..#...#.",
+	"..##..f..",
+	"~~..#..~~",
+	"..f..##..",
+	".#...#..f",
+	"..f..~...",
+	".....~~..",
+}
+
+// newTerrain lays out the terrain for a game, or returns nil for a plain
+// board. The map only fits 9x9 boards; bigger or smaller ones stay plain.
+func newTerrain(rules Rules) [][]Terrain {
+	if !rules.Terrain || len(wildsMap) != rules.BoardSize {
+		return nil
+	}
+	kinds := map[byte]Terrain{'#': Wall, 'f': Forest, '~': Water}
+	terrain := make([][]Terrain, len(wildsMap))
+	for y, row := range wildsMap {
+		terrain[y] = make([]Terrain, len(row))
+		for x := range row {
+			terrain[y][x] = kinds[row[x]]
+		}
+	}
+	return terrain
+}
+
+// TerrainAt returns what the square at x,y is made of
+func (s *State) TerrainAt(x, y int) Terrain {
+	if s.Terrain == nil || !s.OnBoard(x, y) {
+		return Plain
+	}
+	return s.Terrain[y][x]
+}
+
+// DefenseBonus is what a unit adds to its die when it's attacked: its
+// class's bonus, plus cover if it's standing in forest
+func (s *State) DefenseBonus(unit *Unit) int {
+	bonus := s.ClassOf(unit).DefenseBonus
+	if s.TerrainAt(unit.X, unit.Y) == Forest {
+		bonus += ForestBonus
+	}
+	return bonus
+}
+
+// moveCosts works out how much of its move it costs unit to reach each
+// square, stepping one square at a time in any direction. Wading into water
+// costs WaterCost and walls can't be crossed; other units can be slipped
+// past. Squares out of reach are -1. It's nil on boards without terrain,
+// where the cost is just the distance.
+func (s *State) moveCosts(unit *Unit) [][]int {
+	if s.Terrain == nil {
+		return nil
+	}
+	size := s.Rules.BoardSize
+	costs := make([][]int, size)
+	for y := range costs {
+		costs[y] = make([]int, size)
+		for x := range costs[y] {
+			costs[y][x] = -1
+		}
+	}
+	costs[unit.Y][unit.X] = 0
+
+	// Every step costs at least 1, so a pass per point of movement finds the
+	// cheapest way to everywhere in reach
+	reach := s.ClassOf(unit).MoveRange
+	for range reach {
+		for y := range costs {
+			for x, cost := range costs[y] {
+				if cost < 0 {
+					continue
+				}
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						step := 1
+						switch s.TerrainAt(nx, ny) {
+						case Wall:
+							continue
+						case Water:
+							step = WaterCost
+						}
+						if !s.OnBoard(nx, ny) || cost+step > reach {
+							continue
+						}
+						if costs[ny][nx] < 0 || cost+step < costs[ny][nx] {
+							costs[ny][nx] = cost + step
+						}
+					}
+				}
+			}
+		}
+	}
+	return costs
+}
+
+// lineOfSight reports whether nothing blocks a shot from x0,y0 to x1,y1:
+// no wall on any square the straight line between them passes through
+func (s *State) lineOfSight(x0, y0, x1, y1 int) bool {
+	if s.Terrain == nil {
+		return true
+	}
+	// Bresenham's line, leaving out both ends
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	x, y := x0, y0
+	for {
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+		if x == x1 && y == y1 {
+			return true
+		}
+		if s.TerrainAt(x, y) == Wall {
+			return false
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+// handleLobbyMessage handles room listing and navigation for a connection.
+// It runs on the connection's reader goroutine, which is the only place
+// client.room is changed. Returns false if msg isn't a lobby message.
+func handleLobbyMessage(client *Client, msg ClientMessage) bool {
+	// A client matchmaking just paired stays put until they have the room
+	switch ActionType(msg.Type) {
+	case ActionFindMatch, ActionCreateRoom, ActionJoinRoom, ActionLeaveRoom:
+		if client.beingMatched() {
+			sendJSON(client, ServerMessage{Type: "error", Error: ErrMatchPending.Error()})
+			return true
+		}
+	}
+
+	switch ActionType(msg.Type) {
+	case ActionListRooms:
+		sendJSON(client, ServerMessage{Type: "rooms", Rooms: listRooms()})
+
+	case ActionFindMatch:
+		handleFindMatch(client)
+
+	case ActionCancelMatch:
+		if !matchmaker.Remove(client) {
+			sendJSON(client, ServerMessage{Type: "error", Error: "Not looking for a match"})
+			return true
+		}
+		sendJSON(client, ServerMessage{Type: "matchCancelled"})
+
+	case ActionCreateRoom:
+		matchmaker.Remove(client)
+		opts := defaultRoomOptions()
+		opts.Private = msg.Private
+		opts.Password = msg.Code
+		if msg.TimeControl != "" {
+			tc, err := parseTimeControl(msg.TimeControl)
+			if err != nil {
+				sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+				return true
+			}
+			opts.TimeControl = tc
+		}
+		if msg.Rules != "" {
+			rules, err := parseRules(msg.Rules)
+			if err != nil {
+				sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+				return true
+			}
+			opts.Rules = rules
+		}
+		room := switchRoom(client, func() (*Room, error) { return createRoom(msg.Room, opts) }, "")
+		if room != nil && room.private {
+			// Only the creator ever sees the code - they share it with their opponent
+			sendJSON(client, ServerMessage{Type: "invite", Room: room.ID, Code: room.inviteCode})
+		}
+
+	case ActionJoinRoom:
+		matchmaker.Remove(client)
+		if client.room != nil && client.room.ID == normalizeRoomID(msg.Room) {
+			sendJSON(client, ServerMessage{Type: "error", Error: "Already in that room"})
+			return true
+		}
+		switchRoom(client, func() (*Room, error) { return enterRoom(msg.Room, msg.Code, msg.Token) }, msg.Token)
+
+	case ActionLeaveRoom:
+		if client.room == nil {
+			sendJSON(client, ServerMessage{Type: "error", Error: "Not in a room"})
+			return true
+		}
+		exitRoom(client.room, client, ActionLeave)
+		client.room = nil
+		sendJSON(client, ServerMessage{Type: "leftRoom"})
+		sendJSON(client, ServerMessage{Type: "rooms", Rooms: listRooms()})
+
+	case ActionReplay:
+		streamReplay(client, msg.GameID)
+
+	case ActionSignIn:
+		if client.room != nil {
+			return false // The room has to know, in case they're seated
+		}
+		if client.api {
+			sendJSON(client, ServerMessage{Type: "error", Error: ErrBotName.Error()})
+			return true
+		}
+		info, err := accounts.SignIn(msg.Name, msg.Key)
+		if err != nil {
+			sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+			return true
+		}
+		signIn(client, info)
+
+	default:
+		return false
+	}
+	return true
+}
+
+// switchRoom moves a client out of its current room (if any) and into the room
+// returned by open, presenting token to resume a held seat. On failure the
+// client stays where it was and nil is returned.
+func switchRoom(client *Client, open func() (*Room, error), token string) *Room {
+	previous := client.room
+
+	room, err := open()
+	if err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+		return nil
+	}
+
+	if previous != nil {
+		exitRoom(previous, client, ActionLeave)
+	}
+	client.room = room
+	room.actions <- Action{Type: ActionJoin, Client: client, Token: token}
+	return room
+}
@@ -3,11 +3,39 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"path/filepath"
 )
 
 func main() {
-	// Start the game manager in its own goroutine
-	go startGameManager()
+	loadConfig()
+
+	// Keep games and replays on disk, picking up any games that were in
+	// progress when the server last stopped
+	if stateDir != "" {
+		fs, err := newFileStore(stateDir)
+		if err != nil {
+			fmt.Println("Error opening state directory:", err)
+		} else {
+			store = fs
+		}
+		if log, err := newFileLog(filepath.Join(stateDir, "replays")); err != nil {
+			fmt.Println("Error opening replay directory:", err)
+		} else {
+			eventLog = log
+		}
+		if a, err := loadAccounts(filepath.Join(stateDir, "accounts.json")); err != nil {
+			fmt.Println("Error loading accounts:", err)
+		} else {
+			accounts = a
+		}
+		restoreRooms()
+	}
+
+	// The default room always exists - other rooms are created from the lobby
+	openDefaultRoom()
+
+	// Pair up players looking for a match
+	go runMatchmaker()
 
 	// Serve static files from the "static" directory
 	fs := http.FileServer(http.Dir("static"))
@@ -16,6 +44,15 @@ func main() {
 	// WebSocket endpoint
 	http.HandleFunc("/ws", handleWebSocket)
 
+	// Same protocol for programs, authenticated with an API token
+	http.HandleFunc("/bot", handleBotSocket)
+
+	// Finished games, for stepping through afterwards
+	http.HandleFunc("/replays", handleReplays)
+
+	// Player ratings
+	http.HandleFunc("/leaderboard", handleLeaderboard)
+
 	fmt.Println("Server starting on http://localhost:8080")
 	err := http.ListenAndServe(":8080", nil)
 	if err != nil {
@@ -1,7 +1,13 @@
 package main
 
 import (
-	"math/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-multiplayer/gridwars"
 
 	"github.com/gorilla/websocket"
 )
@@ -11,490 +17,422 @@ type Client struct {
 	Conn *websocket.Conn
 	Role string // "X", "O", or "spectator"
 	Name string // Player's chosen name
+	room *Room  // Room this client's actions are routed to, nil in the lobby (owned by the reader goroutine)
+	bot  *Bot   // Set for server-run players, which have no connection
+	api  bool   // Connected to /bot with an API token
+
+	account string // Account the client signed in to, "" for guests
+
+	matchMu  sync.Mutex // Guards matching, matched and gone, which matchmaking sets from its own goroutine
+	matching bool       // Paired by matchmaking, until the reader goroutine adopts the room
+	matched  *Room      // Room matchmaking seated the client in, until the reader goroutine adopts it
+	gone     bool       // Disconnected - don't hand over any more matches
+
+	writeMu sync.Mutex // Rooms and the lobby can both write to the connection
 }
 
-// Action represents any event sent to the game manager
+// Action represents any event sent to a room's game manager
 type Action struct {
 	Type   ActionType
 	Client *Client
-	X      int    // For moves
-	Y      int    // For moves
-	Text   string // For chat
-	Name   string // For setName
+	X      int           // For moves
+	Y      int           // For moves
+	Unit   string        // For moves and attacks: the unit acting
+	Class  string        // For pick: the class to draft ("" for a random one)
+	Text   string        // For chat
+	Name   string        // For setName and signIn
+	Key    string        // For signIn: account key
+	Token  string        // For join: session token to resume a held seat
+	Level  string        // For addBot: difficulty
+	Done   chan struct{} // Closed once the action has been handled (optional)
 }
 
-// Channels for communication
-var (
-	actions = make(chan Action)      // All actions go here
-	clients = make(map[*Client]bool) // All connected clients
-)
+// TickInterval is how often a room checks its timers (held seats, dice, clocks)
+const TickInterval = 250 * time.Millisecond
+
+// run is the single goroutine that owns all of this room's state.
+// It exits when the room is shut down.
+func (r *Room) run() {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	defer r.deleteSnapshot() // Room is gone for good
+
+	for !r.closing {
+		select {
+		case action, ok := <-r.actions:
+			if !ok {
+				return
+			}
+			r.handleAction(action)
+			r.tickClock(time.Now()) // Start or stop the clock for whatever the action changed
+			r.saveSnapshot(time.Now())
+
+		case now := <-ticker.C:
+			r.expireSeats(now)
+			r.checkRollDeadline(now)
+			r.checkPickDeadline(now)
+			r.tickClock(now)
+			r.runBots(now)
+			r.checkRotation(now)
+			r.recordEnd()
+			r.publishInfo()
+			if now.Sub(r.savedAt) >= SnapshotInterval {
+				r.saveSnapshot(now)
+			}
+		}
+	}
+}
 
-// startGameManager runs the single goroutine that owns all game state
-func startGameManager() {
-	for {
-		action := <-actions // Wait for an action
+// handleAction dispatches a single action to its handler
+func (r *Room) handleAction(action Action) {
+	switch action.Type {
+	case ActionJoin:
+		r.handleJoin(action.Client, action.Token)
 
-		switch action.Type {
-		case ActionJoin:
-			handleJoin(action.Client)
+	case ActionLeave:
+		r.handleLeave(action.Client, false)
 
-		case ActionLeave:
-			handleLeave(action.Client)
+	case ActionDisconnect:
+		r.handleLeave(action.Client, true)
 
-		case ActionMove:
-			handleMoveAction(action.Client, action.X, action.Y)
+	case ActionMove:
+		r.handleMoveAction(action.Client, action.Unit, action.X, action.Y)
 
-		case ActionAttack:
-			handleAttackAction(action.Client, action.X, action.Y)
+	case ActionAttack:
+		r.handleAttackAction(action.Client, action.Unit, action.X, action.Y)
 
-		case ActionRoll:
-			handleRollAction(action.Client)
+	case ActionRoll:
+		r.handleRollAction(action.Client)
 
-		case ActionReset:
-			handleResetAction()
+	case ActionPick:
+		r.handlePick(action.Client, action.Class)
 
-		case ActionChat:
-			handleChatAction(action.Client, action.Text)
+	case ActionReset, ActionRematch:
+		r.handleRematchAction(action.Client)
 
-		case ActionSetName:
-			handleSetName(action.Client, action.Name)
-		}
+	case ActionAcceptRematch:
+		r.handleRematchAnswer(action.Client, true)
+
+	case ActionDeclineRematch:
+		r.handleRematchAnswer(action.Client, false)
+
+	case ActionResign:
+		r.handleResign(action.Client)
+
+	case ActionOfferDraw:
+		r.handleOfferDraw(action.Client)
+
+	case ActionAcceptDraw:
+		r.handleDrawAnswer(action.Client, true)
+
+	case ActionDeclineDraw:
+		r.handleDrawAnswer(action.Client, false)
+
+	case ActionJoinQueue:
+		r.handleJoinQueue(action.Client)
+
+	case ActionLeaveQueue:
+		r.handleLeaveQueue(action.Client)
+
+	case ActionChat:
+		r.handleChatAction(action.Client, action.Text)
+
+	case ActionSetName:
+		r.handleSetName(action.Client, action.Name)
+
+	case ActionAddBot:
+		r.handleAddBot(action.Client, action.Level)
+
+	case ActionSignIn:
+		r.handleSignIn(action.Client, action.Name, action.Key)
+	}
+
+	// Close the replay log if that decided the game
+	r.recordEnd()
+
+	// Keep the lobby listing in sync
+	r.publishInfo()
+
+	if action.Done != nil {
+		close(action.Done)
 	}
 }
 
+// MaxClients is the most clients (players and spectators) allowed in one room
 const MaxClients = 10
 
-func handleJoin(client *Client) {
-	// Check connection limit
-	if len(clients) >= MaxClients {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Server full (max 10 players)"})
-		client.Conn.Close()
+func (r *Room) handleJoin(client *Client, token string) {
+	r.clients[client] = true
+
+	// Reconnecting player presenting their session token gets their seat back
+	if seat := r.resumeSeat(client, token); seat != nil {
+		client.Role = seat.Mark
+		sendJSON(client, r.assignedMessage(client))
+		r.send(client, ServerMessage{Type: "state", Game: r.game})
+		r.sendCombatSnapshot(client)
+		r.sendDraft(client)
+		r.sendProposal(client)
+		r.broadcastToAll(ServerMessage{
+			Type:    "chat",
+			From:    "system",
+			Message: client.Role + " reconnected",
+		})
 		return
 	}
 
 	// Assign role: first player is X, second is O, rest are spectators
-	if game.PlayerX == nil {
-		client.Role = "X"
-		game.PlayerX = &Player{Conn: client.Conn, Mark: "X"}
-	} else if game.PlayerO == nil {
-		client.Role = "O"
-		game.PlayerO = &Player{Conn: client.Conn, Mark: "O"}
-	} else {
-		client.Role = "spectator"
+	var seat *Seat
+	if r.seatFor("X") == nil {
+		seat = r.takeSeat(client, "X")
+	} else if r.seatFor("O") == nil {
+		seat = r.takeSeat(client, "O")
 	}
 
-	clients[client] = true
+	client.Role = "spectator"
+	if seat != nil {
+		client.Role = seat.Mark
+	}
 
 	// Tell this client their role
-	sendJSON(client.Conn, ServerMessage{Type: "assigned", Mark: client.Role})
+	sendJSON(client, r.assignedMessage(client))
 
 	// Send current game state
-	sendJSON(client.Conn, ServerMessage{Type: "state", Game: game})
+	r.send(client, ServerMessage{Type: "state", Game: r.game})
+	r.sendCombatSnapshot(client)
+	r.sendDraft(client)
+	r.sendProposal(client)
+	r.broadcastQueue()
 
 	// Announce to everyone
-	broadcastToAll(ServerMessage{
+	r.broadcastToAll(ServerMessage{
 		Type:    "chat",
 		From:    "system",
 		Message: client.Role + " joined",
 	})
 }
 
-func handleLeave(client *Client) {
-	delete(clients, client)
+// assignedMessage tells a client their role, including the session token
+// a player needs to reclaim their seat after a disconnect
+func (r *Room) assignedMessage(client *Client) ServerMessage {
+	msg := ServerMessage{Type: "assigned", Mark: client.Role, Room: r.ID, Bot: client.api}
+	if seat := r.seatFor(client.Role); seat != nil && seat.Client == client {
+		msg.Token = seat.Token
+	}
+	return msg
+}
+
+// handleLeave removes a client from the room. A player whose connection
+// dropped keeps their seat for a grace period; leaving on purpose frees it.
+func (r *Room) handleLeave(client *Client, disconnected bool) {
+	if !r.clients[client] {
+		return // Its seat was taken over by the player reconnecting
+	}
+	delete(r.clients, client)
+	if r.removeFromQueue(client) {
+		r.broadcastQueue()
+	}
 
-	// If a player left, clear their slot
-	if client.Role == "X" {
-		game.PlayerX = nil
-	} else if client.Role == "O" {
-		game.PlayerO = nil
+	// If a player left, hold or clear their slot
+	message := client.Role + " left"
+	if seat := r.seatFor(client.Role); seat != nil && seat.Client == client {
+		if disconnected && r.holdSeat(seat) {
+			message = fmt.Sprintf("%s disconnected - holding their seat for %s", client.Role, time.Until(seat.HeldUntil).Round(time.Second))
+		} else {
+			r.freeSeat(seat.Mark)
+		}
 	}
 
 	// Announce to everyone
-	broadcastToAll(ServerMessage{
+	r.broadcastToAll(ServerMessage{
 		Type:    "chat",
 		From:    "system",
-		Message: client.Role + " left",
+		Message: message,
 	})
+
+	// The client may stay connected in the lobby
+	client.Role = ""
 }
 
-func handleMoveAction(client *Client, x, y int) {
+func (r *Room) handleMoveAction(client *Client, unit string, x, y int) {
 	// Only players can move
 	if client.Role != "X" && client.Role != "O" {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Spectators cannot move"})
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot move"})
 		return
 	}
 
-	// Validate turn
-	if game.Turn != client.Role {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Not your turn"})
+	if err := r.apply(gridwars.Action{Type: gridwars.ActionMove, Mark: client.Role, Unit: unit, X: x, Y: y}); err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
 		return
 	}
 
-	// Validate game not over
-	if game.Winner != "" {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Game is over"})
-		return
-	}
-
-	// Get the player's unit
-	var unit *Unit
-	if client.Role == "X" {
-		unit = game.UnitX
-	} else {
-		unit = game.UnitO
-	}
+	// Broadcast to everyone
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+}
 
-	// Validate move is within 3 squares (Chebyshev distance)
-	dx := abs(x - unit.X)
-	dy := abs(y - unit.Y)
-	distance := dx
-	if dy > dx {
-		distance = dy
+// turnEnded settles the clock and any draw offer once a player's turn is over
+func (r *Room) turnEnded(mark string) {
+	if clock := r.game.Clock; clock != nil {
+		clock.turnEnded(mark)
 	}
-	if distance == 0 || distance > 3 {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Can only move up to 3 squares"})
-		return
+	// A draw offer stands until the player it was made to takes their turn
+	if r.game.DrawOffer != "" && r.game.DrawOffer != mark {
+		r.game.DrawOffer = ""
 	}
+}
+
+// clockRunning reports whether the player on turn is being timed: both seats
+// must be taken, the draft over, the game not over and no combat waiting on dice
+func (r *Room) clockRunning() bool {
+	return r.seatFor("X") != nil && r.seatFor("O") != nil &&
+		r.game.Draft == nil && r.game.Winner == "" && r.pendingCombat == nil
+}
 
-	// Validate position is in bounds
-	if x < 0 || x >= BoardSize || y < 0 || y >= BoardSize {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Out of bounds"})
+// tickClock charges time to the player on turn and handles running out.
+// Clients only count down a running clock, so they're told whenever it
+// starts or stops.
+func (r *Room) tickClock(now time.Time) {
+	clock := r.game.Clock
+	if clock == nil {
 		return
 	}
-
-	// Validate destination is empty
-	if game.Board[y][x] != "" {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Square occupied"})
+	wasRunning := clock.Running
+	if !clock.tick(now, r.game.Turn, r.clockRunning()) {
+		if clock.Running != wasRunning {
+			r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+		}
 		return
 	}
 
-	// Move the unit
-	game.Board[unit.Y][unit.X] = "" // Clear old position
-	unit.X = x
-	unit.Y = y
-	game.Board[y][x] = client.Role // Set new position
-
-	// Check if landed on a power-up
-	checkPowerUpCollection(unit, client.Role)
-
-	// Switch turns
-	if game.Turn == "X" {
-		game.Turn = "O"
+	mark := r.game.Turn
+	if clock.forfeitsOnExpiry() {
+		r.game.Finish(gridwars.Other(mark), gridwars.ResultTimeout)
+		clock.Running = false
+		r.broadcastToAll(ServerMessage{
+			Type:    "chat",
+			From:    "system",
+			Message: mark + " ran out of time - " + r.game.Winner + " wins",
+		})
 	} else {
-		game.Turn = "X"
-	}
-
-	// Maybe spawn a power-up for the next turn
-	maybeSpawnPowerUp()
-
-	// Broadcast to everyone
-	broadcastToAll(ServerMessage{Type: "state", Game: game})
-}
-
-// abs returns the absolute value of n
-func abs(n int) int {
-	if n < 0 {
-		return -n
-	}
-	return n
+		r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: mark})
+		r.recordState(Event{Type: EventPass, Mark: mark})
+		r.broadcastToAll(ServerMessage{
+			Type:    "chat",
+			From:    "system",
+			Message: mark + " ran out of time - turn passed",
+		})
+	}
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
 }
 
 // resetGame clears the board and reinitializes units
-func resetGame() {
-	game.Board = [BoardSize][BoardSize]string{}
-	game.Turn = "X"
-	game.Winner = ""
-	game.PowerUps = nil
-	pendingCombat = nil
-	game.initializeUnits()
+func (r *Room) resetGame() {
+	r.game.State = gridwars.NewState(r.rules)
+	r.game.DrawOffer = ""
+	r.pendingCombat = nil
+	r.pickDeadline = time.Time{}
+	if r.game.Clock != nil {
+		r.game.Clock.reset()
+	}
+	r.seedGame(randomSeed())
+	r.beginLog()
 }
 
-func handleResetAction() {
-	resetGame()
+// startRematch resets the board and swaps who plays X and O
+func (r *Room) startRematch() {
+	r.resetGame()
 
-	// Swap players on manual reset
-	if game.PlayerX != nil && game.PlayerO != nil {
-		for client := range clients {
+	// Swap players on rematch
+	if r.seatFor("X") != nil && r.seatFor("O") != nil {
+		for client := range r.clients {
 			if client.Role == "X" {
 				client.Role = "O"
 			} else if client.Role == "O" {
 				client.Role = "X"
 			}
 		}
-		game.PlayerX, game.PlayerO = game.PlayerO, game.PlayerX
-		game.PlayerX.Mark = "X"
-		game.PlayerO.Mark = "O"
+		accountX, accountO := r.game.Accounts["X"], r.game.Accounts["O"]
+		r.setAccount("X", accountO)
+		r.setAccount("O", accountX)
+		r.seats["X"], r.seats["O"] = r.seats["O"], r.seats["X"]
+		r.seats["X"].Mark = "X"
+		r.seats["O"].Mark = "O"
 	}
 
 	// Tell everyone their (possibly new) roles and the new state
-	for client := range clients {
-		sendJSON(client.Conn, ServerMessage{Type: "assigned", Mark: client.Role})
+	for client := range r.clients {
+		sendJSON(client, r.assignedMessage(client))
 	}
-	broadcastToAll(ServerMessage{Type: "state", Game: game})
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
 }
 
-func handleAttackAction(client *Client, x, y int) {
+func (r *Room) handleAttackAction(client *Client, unit string, x, y int) {
 	// Only players can attack
 	if client.Role != "X" && client.Role != "O" {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Spectators cannot attack"})
-		return
-	}
-
-	// Validate turn
-	if game.Turn != client.Role {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Not your turn"})
-		return
-	}
-
-	// Validate game not over
-	if game.Winner != "" {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Game is over"})
-		return
-	}
-
-	// Can't start new combat if one is pending
-	if pendingCombat != nil {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Combat already in progress"})
-		return
-	}
-
-	// Get attacker and defender units and marks
-	var attacker, defender *Unit
-	var attackerMark, defenderMark string
-	if client.Role == "X" {
-		attacker = game.UnitX
-		defender = game.UnitO
-		attackerMark = "X"
-		defenderMark = "O"
-	} else {
-		attacker = game.UnitO
-		defender = game.UnitX
-		attackerMark = "O"
-		defenderMark = "X"
-	}
-
-	// Check if clicking on the enemy position
-	if defender.X != x || defender.Y != y {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "No enemy at that position"})
-		return
-	}
-
-	// Check if enemy is within 1 square (adjacent)
-	dx := abs(attacker.X - defender.X)
-	dy := abs(attacker.Y - defender.Y)
-	if dx > 1 || dy > 1 {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Enemy not in range"})
-		return
-	}
-
-	// Check if attacker has attack boost - instant 6 damage, no dice!
-	if attacker.AttackBoost {
-		attacker.AttackBoost = false // Consume the boost
-
-		// Build instant combat result
-		combat := &CombatResult{
-			AttackerMark: attackerMark,
-			DefenderMark: defenderMark,
-			AttackerRoll: 6, // Show as max roll
-			DefenderRoll: 0, // No defense
-			Winner:       "attacker",
-			LoserMark:    defenderMark,
-			Damage:       6,
-		}
-
-		// Apply damage immediately
-		defender.HP -= 6
-		if defender.HP < 0 {
-			defender.HP = 0
-		}
-
-		// Check for winner
-		game.checkWinner()
-
-		// If defender eliminated, remove from board
-		if defender.HP <= 0 {
-			game.Board[defender.Y][defender.X] = ""
-		}
-
-		// Switch turns (if game not over)
-		if game.Winner == "" {
-			if game.Turn == "X" {
-				game.Turn = "O"
-			} else {
-				game.Turn = "X"
-			}
-		}
-
-		// Maybe spawn power-up
-		maybeSpawnPowerUp()
-
-		// Broadcast boosted attack result
-		broadcastToAll(ServerMessage{Type: "combat_boosted", Game: game, Combat: combat})
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot attack"})
 		return
 	}
 
-	// Normal combat - Pre-roll dice (server determines outcome now, but don't reveal yet)
-	attackRoll := rand.Intn(6) + 1 // 1-6
-	defendRoll := rand.Intn(6) + 1 // 1-6
-
-	// Build combat result (rolls hidden from clients until they click)
-	combat := &CombatResult{
-		AttackerMark:   attackerMark,
-		DefenderMark:   defenderMark,
-		AttackerRoll:   attackRoll,
-		DefenderRoll:   defendRoll,
-		AttackerRolled: false,
-		DefenderRolled: false,
+	// The dice (or boosted hit) are announced as the rules report them
+	if err := r.apply(gridwars.Action{Type: gridwars.ActionAttack, Mark: client.Role, Unit: unit, X: x, Y: y}); err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
 	}
-
-	// Calculate outcome (but don't apply yet)
-	if attackRoll >= defendRoll {
-		combat.Winner = "attacker"
-		combat.LoserMark = defenderMark
-		combat.Damage = attackRoll - defendRoll
-		if combat.Damage < 1 {
-			combat.Damage = 1
-		}
-	} else {
-		combat.Winner = "defender"
-		combat.LoserMark = attackerMark
-		combat.Damage = defendRoll - attackRoll
-		if combat.Damage < 1 {
-			combat.Damage = 1
-		}
-	}
-
-	// Store pending combat
-	pendingCombat = &PendingCombat{
-		Combat:   combat,
-		Attacker: attacker,
-		Defender: defender,
-	}
-
-	// Broadcast combat start (without revealing rolls)
-	broadcastToAll(ServerMessage{
-		Type: "combat_start",
-		Combat: &CombatResult{
-			AttackerMark:   attackerMark,
-			DefenderMark:   defenderMark,
-			AttackerRolled: false,
-			DefenderRolled: false,
-		},
-	})
 }
 
-func handleRollAction(client *Client) {
-	// Must have pending combat
-	if pendingCombat == nil {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "No combat in progress"})
-		return
+func (r *Room) handleRollAction(client *Client) {
+	err := r.apply(gridwars.Action{Type: gridwars.ActionRoll, Mark: client.Role})
+	switch {
+	case err == nil, errors.Is(err, gridwars.ErrAlreadyRolled):
+		// Nothing more to say to a double click
+	case errors.Is(err, gridwars.ErrNotAPlayer):
+		sendJSON(client, ServerMessage{Type: "error", Error: gridwars.ErrNotInCombat.Error()})
+	default:
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
 	}
+}
 
-	combat := pendingCombat.Combat
-
-	// Check if this player is part of the combat
-	isAttacker := client.Role == combat.AttackerMark
-	isDefender := client.Role == combat.DefenderMark
+// rollDice reveals a combatant's die on their behalf, resolving the combat
+// if that was the last one
+func (r *Room) rollDice(mark string) {
+	r.apply(gridwars.Action{Type: gridwars.ActionRoll, Mark: mark})
+}
 
-	if !isAttacker && !isDefender {
-		sendJSON(client.Conn, ServerMessage{Type: "error", Error: "You are not in this combat"})
+// checkRollDeadline rolls for whichever combatant is holding up the combat
+// once their time to click the dice has run out
+func (r *Room) checkRollDeadline(now time.Time) {
+	if r.pendingCombat == nil || r.pendingCombat.Deadline.IsZero() || now.Before(r.pendingCombat.Deadline) {
 		return
 	}
 
-	// Mark this player as having rolled
-	if isAttacker {
-		if combat.AttackerRolled {
-			return // Already rolled
-		}
-		combat.AttackerRolled = true
-	} else {
-		// Defender can only roll after attacker
-		if !combat.AttackerRolled {
-			sendJSON(client.Conn, ServerMessage{Type: "error", Error: "Wait for attacker to roll first"})
-			return
-		}
-		if combat.DefenderRolled {
-			return // Already rolled
-		}
-		combat.DefenderRolled = true
-	}
-
-	// Broadcast that someone rolled
-	// Include attacker's roll if they've rolled (so defender knows what to beat)
-	rolledMsg := &CombatResult{
-		AttackerMark:   combat.AttackerMark,
-		DefenderMark:   combat.DefenderMark,
-		AttackerRolled: combat.AttackerRolled,
-		DefenderRolled: combat.DefenderRolled,
-	}
-	if combat.AttackerRolled {
-		rolledMsg.AttackerRoll = combat.AttackerRoll
+	combat := r.game.Combat
+	mark := combat.DefenderMark
+	if !combat.AttackerRolled {
+		mark = combat.AttackerMark
 	}
-	broadcastToAll(ServerMessage{
-		Type:   "combat_rolled",
-		Combat: rolledMsg,
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: mark + " didn't roll in time - rolling for them",
 	})
-
-	// If both have rolled, resolve combat
-	if combat.AttackerRolled && combat.DefenderRolled {
-		resolveCombat()
-	}
+	r.rollDice(mark)
 }
 
-func resolveCombat() {
-	if pendingCombat == nil {
+// finishCombatFor settles a pending combat when one of its combatants gives
+// up their seat, rolling on their behalf so the board isn't left stuck
+func (r *Room) finishCombatFor(mark string) {
+	combat := r.game.Combat
+	if combat == nil || (mark != combat.AttackerMark && mark != combat.DefenderMark) {
 		return
 	}
 
-	combat := pendingCombat.Combat
-	attacker := pendingCombat.Attacker
-	defender := pendingCombat.Defender
-
-	// Apply damage
-	if combat.Winner == "attacker" {
-		defender.HP -= combat.Damage
-		if defender.HP < 0 {
-			defender.HP = 0
-		}
-	} else {
-		attacker.HP -= combat.Damage
-		if attacker.HP < 0 {
-			attacker.HP = 0
-		}
-	}
-
-	// Check for winner
-	game.checkWinner()
-
-	// If defender eliminated, remove from board
-	if defender.HP <= 0 {
-		game.Board[defender.Y][defender.X] = ""
-	}
-	// If attacker eliminated (from counter), remove from board
-	if attacker.HP <= 0 {
-		game.Board[attacker.Y][attacker.X] = ""
+	if !combat.AttackerRolled {
+		r.rollDice(combat.AttackerMark)
 	}
-
-	// Switch turns (if game not over)
-	if game.Winner == "" {
-		if game.Turn == "X" {
-			game.Turn = "O"
-		} else {
-			game.Turn = "X"
-		}
+	if r.game.Combat != nil && !r.game.Combat.DefenderRolled {
+		r.rollDice(combat.DefenderMark)
 	}
-
-	// Clear pending combat
-	pendingCombat = nil
-
-	// Maybe spawn power-up
-	maybeSpawnPowerUp()
-
-	// Broadcast final combat result and new state
-	broadcastToAll(ServerMessage{Type: "combat", Game: game, Combat: combat})
 }
 
-func handleChatAction(client *Client, text string) {
+func (r *Room) handleChatAction(client *Client, text string) {
 	// Limit message length
 	if len(text) > 200 {
 		text = text[:200]
@@ -502,8 +440,9 @@ func handleChatAction(client *Client, text string) {
 	if len(text) == 0 {
 		return
 	}
+	r.recordChat(client, text)
 
-	broadcastToAll(ServerMessage{
+	r.broadcastToAll(ServerMessage{
 		Type:    "chat",
 		From:    client.Role,
 		Name:    client.Name,
@@ -511,19 +450,44 @@ func handleChatAction(client *Client, text string) {
 	})
 }
 
-func handleSetName(client *Client, name string) {
+func (r *Room) handleSetName(client *Client, name string) {
+	if client.api {
+		sendJSON(client, ServerMessage{Type: "error", Error: ErrBotName.Error()})
+		return
+	}
+
 	// Limit name length
-	if len(name) > 20 {
-		name = name[:20]
+	if len(name) > MaxNameLength {
+		name = name[:MaxNameLength]
 	}
 	if len(name) == 0 {
 		return
 	}
 
+	// Registered names need signing in to, and picking any other name signs out
+	if account, ok := accounts.Lookup(name); ok && !strings.EqualFold(account.Name, client.account) {
+		sendJSON(client, ServerMessage{Type: "error", Error: ErrNameTaken.Error()})
+		return
+	}
+	seated := r.ownsSeat(client)
+	if client.account != "" && !strings.EqualFold(name, client.account) {
+		if seated && r.gameInProgress() {
+			sendJSON(client, ServerMessage{Type: "error", Error: "Finish the game before signing out"})
+			return
+		}
+		client.account = ""
+		if seated {
+			r.setAccount(client.Role, "")
+		}
+	}
+
 	client.Name = name
+	if seated {
+		r.seatFor(client.Role).Name = name
+	}
 
 	// Announce name change
-	broadcastToAll(ServerMessage{
+	r.broadcastToAll(ServerMessage{
 		Type:    "chat",
 		From:    "system",
 		Name:    "",
@@ -531,92 +495,22 @@ func handleSetName(client *Client, name string) {
 	})
 }
 
-// broadcastToAll sends a message to every connected client
-func broadcastToAll(msg ServerMessage) {
-	for client := range clients {
-		sendJSON(client.Conn, msg)
+// broadcastToAll sends a message to every client in the room
+func (r *Room) broadcastToAll(msg ServerMessage) {
+	for client := range r.clients {
+		r.send(client, msg)
 	}
 }
 
-// maybeSpawnPowerUp has a chance to spawn a power-up on an empty square
-func maybeSpawnPowerUp() {
-	// 35% chance to spawn a power-up each turn
-	if rand.Intn(100) >= 35 {
-		return
+// send delivers a room message to one client. Game updates carry hints for
+// the player to move, and API bots also get what they can do next with
+// anything that might have changed it.
+func (r *Room) send(client *Client, msg ServerMessage) {
+	if msg.Game != nil {
+		msg.Hints = msg.Game.Hints()
 	}
-
-	// Limit to 3 power-ups on board at once
-	if len(game.PowerUps) >= 3 {
-		return
-	}
-
-	// Find empty squares (not occupied by units or other power-ups)
-	var emptySquares [][2]int
-	for y := 0; y < BoardSize; y++ {
-		for x := 0; x < BoardSize; x++ {
-			if game.Board[y][x] != "" {
-				continue // Unit here
-			}
-			// Check if power-up already here
-			hasPowerUp := false
-			for _, p := range game.PowerUps {
-				if p.X == x && p.Y == y {
-					hasPowerUp = true
-					break
-				}
-			}
-			if !hasPowerUp {
-				emptySquares = append(emptySquares, [2]int{x, y})
-			}
-		}
-	}
-
-	if len(emptySquares) == 0 {
-		return
-	}
-
-	// Pick random empty square
-	pos := emptySquares[rand.Intn(len(emptySquares))]
-
-	// Pick random type (50/50)
-	powerUpType := "hp"
-	if rand.Intn(2) == 1 {
-		powerUpType = "attack"
-	}
-
-	game.PowerUps = append(game.PowerUps, PowerUp{
-		Type: powerUpType,
-		X:    pos[0],
-		Y:    pos[1],
-	})
-}
-
-// checkPowerUpCollection checks if a unit landed on a power-up and applies it
-func checkPowerUpCollection(unit *Unit, mark string) {
-	for i := len(game.PowerUps) - 1; i >= 0; i-- {
-		p := game.PowerUps[i]
-		if p.X == unit.X && p.Y == unit.Y {
-			// Collect it!
-			if p.Type == "hp" {
-				unit.HP += 3
-				if unit.HP > unit.MaxHP {
-					unit.HP = unit.MaxHP
-				}
-				broadcastToAll(ServerMessage{
-					Type:    "chat",
-					From:    "system",
-					Message: mark + " collected HP boost! (+3 HP)",
-				})
-			} else if p.Type == "attack" {
-				unit.AttackBoost = true
-				broadcastToAll(ServerMessage{
-					Type:    "chat",
-					From:    "system",
-					Message: mark + " collected Attack boost! (Next attack deals 6 damage)",
-				})
-			}
-			// Remove from board
-			game.PowerUps = append(game.PowerUps[:i], game.PowerUps[i+1:]...)
-		}
+	if client.api && (msg.Game != nil || msg.Combat != nil || msg.Draft != nil) {
+		msg.Legal = r.game.LegalActions(client.Role)
 	}
+	sendJSON(client, msg)
 }
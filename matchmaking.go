@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Matchmaking pairs players of similar rating. Everyone starts out only
+// matching players close to their own rating, and the window widens the
+// longer they wait, so nobody waits forever.
+const (
+	MatchBaseWindow   = 50              // Rating difference accepted straight away
+	MatchWindowGrowth = 50              // Added to the window every MatchWidenEvery
+	MatchWidenEvery   = 5 * time.Second // How often the window grows
+	MatchInterval     = time.Second     // How often waiting players are paired
+)
+
+// MatchEntry is a player waiting for a match
+type MatchEntry struct {
+	Client *Client
+	Rating int       // Account rating, or InitialRating for guests
+	Since  time.Time // When they started looking
+}
+
+// window is how far from their rating a player will accept an opponent now
+func (e *MatchEntry) window(now time.Time) int {
+	return MatchBaseWindow + MatchWindowGrowth*int(now.Sub(e.Since)/MatchWidenEvery)
+}
+
+// Matchmaker holds the players waiting for a match. Safe for use from any goroutine.
+type Matchmaker struct {
+	mu      sync.Mutex
+	waiting []*MatchEntry // Longest waiting first
+}
+
+// matchmaker is the server's one matchmaking queue
+var matchmaker = &Matchmaker{}
+
+// Add queues a player. Returns false if they were already waiting.
+func (m *Matchmaker) Add(entry *MatchEntry) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.waiting {
+		if e.Client == entry.Client {
+			return false
+		}
+	}
+	m.waiting = append(m.waiting, entry)
+	return true
+}
+
+// Remove takes a player out of the queue. Returns false if they weren't
+// waiting (or were just paired).
+func (m *Matchmaker) Remove(client *Client) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.waiting {
+		if e.Client == client {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pair takes every pair of players that will accept each other out of the
+// queue. The longest waiting player is matched first, to the closest rated
+// player within either of their windows. Paired clients can't go anywhere
+// else until startMatch hands them their room.
+func (m *Matchmaker) Pair(now time.Time) [][2]*MatchEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pairs [][2]*MatchEntry
+	paired := make(map[*MatchEntry]bool)
+	for i, a := range m.waiting {
+		if paired[a] {
+			continue
+		}
+		var best *MatchEntry
+		bestGap := 0
+		for _, b := range m.waiting[i+1:] {
+			gap := max(a.Rating-b.Rating, b.Rating-a.Rating)
+			if paired[b] || gap > max(a.window(now), b.window(now)) {
+				continue
+			}
+			if best == nil || gap < bestGap {
+				best, bestGap = b, gap
+			}
+		}
+		if best != nil {
+			paired[a], paired[best] = true, true
+			pairs = append(pairs, [2]*MatchEntry{a, best})
+			a.Client.setMatching(true)
+			best.Client.setMatching(true)
+		}
+	}
+
+	waiting := m.waiting[:0]
+	for _, e := range m.waiting {
+		if !paired[e] {
+			waiting = append(waiting, e)
+		}
+	}
+	m.waiting = waiting
+	return pairs
+}
+
+// runMatchmaker pairs waiting players every MatchInterval, forever
+func runMatchmaker() {
+	for now := range time.Tick(MatchInterval) {
+		for _, pair := range matchmaker.Pair(now) {
+			startMatch(pair[0], pair[1])
+		}
+	}
+}
+
+// startMatch opens a private room for two matched players and seats them,
+// in a random order since X moves first. API bots are matched like anyone
+// else and told they're playing as a bot. Each client's reader goroutine
+// picks the room up with adoptMatch.
+func startMatch(a, b *MatchEntry) {
+	opts := defaultRoomOptions()
+	opts.Private = true
+	room, err := createRoom("", opts)
+	if err != nil {
+		fmt.Println("Error creating match room:", err)
+		for _, e := range []*MatchEntry{a, b} {
+			e.Client.setMatching(false)
+			sendJSON(e.Client, ServerMessage{Type: "error", Error: "Couldn't start the match - try again"})
+		}
+		return
+	}
+	roomsMu.Lock()
+	room.members++ // createRoom only counted one player
+	roomsMu.Unlock()
+
+	if mrand.IntN(2) == 0 {
+		a, b = b, a
+	}
+	for _, e := range []*MatchEntry{a, b} {
+		sendJSON(e.Client, ServerMessage{Type: "matched", Room: room.ID, Code: room.inviteCode, Bot: e.Client.api})
+		room.actions <- Action{Type: ActionJoin, Client: e.Client}
+		if !e.Client.setMatch(room) {
+			// Gone before they could be handed the room - their seat is held as usual
+			exitRoom(room, e.Client, ActionDisconnect)
+		}
+	}
+}
+
+// ErrMatchPending is returned for trying to go somewhere else while
+// matchmaking is opening the room a client was just paired into
+var ErrMatchPending = errors.New("Found a match - joining it now")
+
+// handleFindMatch takes a client out of any room they're in and queues them
+// for a match, against a person or a bot. Only called from the client's
+// reader goroutine.
+func handleFindMatch(client *Client) {
+	if client.room != nil {
+		exitRoom(client.room, client, ActionLeave)
+		client.room = nil
+		sendJSON(client, ServerMessage{Type: "leftRoom"})
+	}
+
+	rating := InitialRating
+	if info, ok := accounts.Lookup(client.account); ok {
+		rating = info.Rating
+	}
+	if !matchmaker.Add(&MatchEntry{Client: client, Rating: rating, Since: time.Now()}) {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Already looking for a match"})
+		return
+	}
+	sendJSON(client, ServerMessage{Type: "searching", Message: fmt.Sprintf("Looking for an opponent rated near %d", rating)})
+}
+
+// setMatching marks a client as paired, or not any more if their match
+// couldn't be started
+func (c *Client) setMatching(matching bool) {
+	c.matchMu.Lock()
+	defer c.matchMu.Unlock()
+	c.matching = matching
+}
+
+// beingMatched reports whether a client has been paired but hasn't yet
+// adopted the room. Rooms only seat a client from one goroutine at a time,
+// so they mustn't join another room until then.
+func (c *Client) beingMatched() bool {
+	c.matchMu.Lock()
+	defer c.matchMu.Unlock()
+	return c.matching
+}
+
+// setMatch hands a client the room matchmaking seated them in. Returns
+// false if they've already disconnected.
+func (c *Client) setMatch(room *Room) bool {
+	c.matchMu.Lock()
+	defer c.matchMu.Unlock()
+	if c.gone {
+		return false
+	}
+	c.matched = room
+	return true
+}
+
+// adoptMatch routes the client's messages to the room matchmaking seated
+// them in, if it has since the last message. If they went somewhere else
+// in the meantime, they leave the match. closing is set when the client is
+// disconnecting, after which no more matches are handed over.
+// Only called from the client's reader goroutine.
+func adoptMatch(client *Client, closing bool) {
+	client.matchMu.Lock()
+	room := client.matched
+	client.matched = nil
+	if room != nil {
+		client.matching = false
+	}
+	client.gone = client.gone || closing
+	client.matchMu.Unlock()
+
+	if room == nil {
+		return
+	}
+	if client.room != nil {
+		exitRoom(room, client, ActionLeave)
+		return
+	}
+	client.room = room
+}
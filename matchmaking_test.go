@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchmaker_PairsClosestWithinWindow(t *testing.T) {
+	m := &Matchmaker{}
+	start := time.Now()
+	a := &MatchEntry{Client: &Client{}, Rating: 1200, Since: start}
+	b := &MatchEntry{Client: &Client{}, Rating: 1400, Since: start}
+	c := &MatchEntry{Client: &Client{}, Rating: 1240, Since: start.Add(time.Second)}
+	for _, e := range []*MatchEntry{a, b, c} {
+		m.Add(e)
+	}
+	if m.Add(&MatchEntry{Client: a.Client}) {
+		t.Error("expected a player already waiting not to be queued twice")
+	}
+
+	pairs := m.Pair(start.Add(time.Second))
+	if len(pairs) != 1 || pairs[0][0] != a || pairs[0][1] != c {
+		t.Fatalf("expected 1200 to be paired with 1240, got %v", pairs)
+	}
+	if len(m.waiting) != 1 || m.waiting[0] != b {
+		t.Errorf("expected 1400 still waiting, got %v", m.waiting)
+	}
+}
+
+func TestMatchmaker_WindowWidensOverTime(t *testing.T) {
+	m := &Matchmaker{}
+	start := time.Now()
+	m.Add(&MatchEntry{Client: &Client{}, Rating: 1200, Since: start})
+	m.Add(&MatchEntry{Client: &Client{}, Rating: 1400, Since: start})
+
+	if pairs := m.Pair(start.Add(10 * time.Second)); len(pairs) != 0 {
+		t.Fatalf("expected 200 points apart to be too far at first, got %v", pairs)
+	}
+	if pairs := m.Pair(start.Add(3 * MatchWidenEvery)); len(pairs) != 1 {
+		t.Errorf("expected a match once the window reached 200, got %v", pairs)
+	}
+}
+
+func TestStartMatch_SeatsBothInAPrivateRoom(t *testing.T) {
+	a, b := &Client{}, &Client{}
+	startMatch(&MatchEntry{Client: a, Rating: 1200}, &MatchEntry{Client: b, Rating: 1210})
+	adoptMatch(a, false)
+	adoptMatch(b, false)
+
+	if a.room == nil || a.room != b.room {
+		t.Fatalf("expected both players handed the same room, got %v and %v", a.room, b.room)
+	}
+	room := a.room
+	defer exitRoom(room, a, ActionLeave)
+	defer exitRoom(room, b, ActionLeave)
+
+	done := make(chan struct{})
+	room.actions <- Action{Client: a, Done: done}
+	<-done
+
+	info := room.Info()
+	if !info.Private || len(info.Players) != 2 {
+		t.Errorf("expected a private room with both seats taken, got %+v", info)
+	}
+	if a.Role == b.Role || a.Role == "spectator" || b.Role == "spectator" {
+		t.Errorf("expected one X and one O, got %q and %q", a.Role, b.Role)
+	}
+}
+
+func TestStartMatch_PlayerGoneBeforeHandoff(t *testing.T) {
+	a, b := &Client{}, &Client{}
+	adoptMatch(b, true) // b disconnects while they're being paired
+	startMatch(&MatchEntry{Client: a}, &MatchEntry{Client: b})
+	adoptMatch(a, false)
+	defer exitRoom(a.room, a, ActionLeave)
+
+	done := make(chan struct{})
+	a.room.actions <- Action{Client: a, Done: done}
+	<-done
+
+	info := a.room.Info()
+	if len(info.Players) != 2 || !info.Players[0].Away && !info.Players[1].Away {
+		t.Errorf("expected the missing player's seat to be held for them, got %+v", info.Players)
+	}
+}
+
+func TestStartMatch_PairsAPIBots(t *testing.T) {
+	bot, human := &Client{Name: "alphabot", api: true}, &Client{}
+	handleFindMatch(bot)
+	if !matchmaker.Remove(bot) {
+		t.Fatal("expected an API bot to be queued for a match")
+	}
+
+	startMatch(&MatchEntry{Client: bot}, &MatchEntry{Client: human})
+	adoptMatch(bot, false)
+	adoptMatch(human, false)
+	room := bot.room
+	defer exitRoom(room, bot, ActionLeave)
+	defer exitRoom(room, human, ActionLeave)
+
+	done := make(chan struct{})
+	room.actions <- Action{Client: bot, Done: done}
+	<-done
+
+	info := room.Info()
+	bots := 0
+	for _, p := range info.Players {
+		if p.Bot {
+			bots++
+		}
+	}
+	if len(info.Players) != 2 || bots != 1 {
+		t.Errorf("expected the bot seated and tagged opposite the human, got %+v", info.Players)
+	}
+}
+
+func TestStartMatch_PairedPlayerCantJoinAnotherRoomFirst(t *testing.T) {
+	m := &Matchmaker{}
+	a, b := &Client{}, &Client{}
+	m.Add(&MatchEntry{Client: a, Rating: 1200})
+	m.Add(&MatchEntry{Client: b, Rating: 1200})
+	pairs := m.Pair(time.Now())
+	if len(pairs) != 1 {
+		t.Fatalf("expected a pair, got %v", pairs)
+	}
+
+	// a asks for a room of their own before matchmaking has opened theirs
+	handleLobbyMessage(a, ClientMessage{Type: string(ActionCreateRoom), Room: "match-race-test"})
+	if a.room != nil {
+		defer exitRoom(a.room, a, ActionLeave)
+		t.Fatalf("expected a paired player kept out of other rooms, got %q", a.room.ID)
+	}
+
+	startMatch(pairs[0][0], pairs[0][1])
+	adoptMatch(a, false)
+	adoptMatch(b, false)
+	room := a.room
+	defer exitRoom(room, a, ActionLeave)
+	defer exitRoom(room, b, ActionLeave)
+
+	done := make(chan struct{})
+	room.actions <- Action{Client: a, Done: done}
+	<-done
+	if seat := room.seatFor(a.Role); seat == nil || seat.Client != a {
+		t.Errorf("expected a seated in the match room, got role %q", a.Role)
+	}
+	if a.beingMatched() {
+		t.Error("expected a free to move on once they have the room")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import "go-multiplayer/gridwars"
+
+// handleResign ends the game in the opponent's favour
+func (r *Room) handleResign(client *Client) {
+	if !r.canConcede(client) {
+		return
+	}
+
+	r.apply(gridwars.Action{Type: gridwars.ActionResign, Mark: client.Role})
+	r.endGame(client.Role + " resigned - " + r.game.Winner + " wins")
+}
+
+// handleOfferDraw offers the opponent a draw. If they'd already offered one,
+// this accepts it.
+func (r *Room) handleOfferDraw(client *Client) {
+	if !r.canConcede(client) {
+		return
+	}
+
+	if r.game.DrawOffer == gridwars.Other(client.Role) {
+		r.game.Finish(gridwars.WinnerDraw, gridwars.ResultDraw)
+		r.endGame("Draw agreed")
+		return
+	}
+
+	r.game.DrawOffer = client.Role
+	r.broadcastToAll(ServerMessage{Type: "draw_offered", From: client.Role})
+}
+
+// handleDrawAnswer handles the opponent accepting or declining a draw offer
+func (r *Room) handleDrawAnswer(client *Client, accept bool) {
+	if !r.canConcede(client) {
+		return
+	}
+	if r.game.DrawOffer == "" || r.game.DrawOffer == client.Role {
+		sendJSON(client, ServerMessage{Type: "error", Error: "No draw has been offered to you"})
+		return
+	}
+
+	if accept {
+		r.game.Finish(gridwars.WinnerDraw, gridwars.ResultDraw)
+		r.endGame("Draw agreed")
+		return
+	}
+
+	r.broadcastToAll(ServerMessage{Type: "draw_declined", From: client.Role})
+	r.game.DrawOffer = ""
+}
+
+// canConcede checks a client may resign or deal with draws right now,
+// sending them an error if not
+func (r *Room) canConcede(client *Client) bool {
+	if client.Role != "X" && client.Role != "O" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot resign or offer draws"})
+		return false
+	}
+	if r.game.Winner != "" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Game is over"})
+		return false
+	}
+	if r.pendingCombat != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Wait for combat to finish"})
+		return false
+	}
+	return true
+}
+
+// checkAbandoned awards the game to the opponent when a player gives up
+// their seat (or never comes back) partway through a game
+func (r *Room) checkAbandoned(mark string) {
+	opponent := gridwars.Other(mark)
+	if r.game.Winner != "" || r.game.Turns == 0 || r.seatFor(opponent) == nil {
+		return
+	}
+
+	r.game.Finish(opponent, gridwars.ResultAbandoned)
+	r.endGame(mark + " abandoned the game - " + opponent + " wins")
+}
+
+// endGame announces a game that ended off the board and broadcasts the result
+func (r *Room) endGame(message string) {
+	r.game.DrawOffer = ""
+	if r.game.Clock != nil {
+		r.game.Clock.Running = false
+	}
+
+	r.broadcastToAll(ServerMessage{
+		Type:    "chat",
+		From:    "system",
+		Message: message,
+	})
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestResign_OpponentWins(t *testing.T) {
+	r := newRoom("resign-test", RoomOptions{})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+
+	r.handleResign(x)
+
+	if r.game.Winner != "O" || r.game.Result != gridwars.ResultResignation {
+		t.Errorf("expected O to win by resignation, got %q/%q", r.game.Winner, r.game.Result)
+	}
+}
+
+func TestDrawOffer_AcceptEndsInDraw(t *testing.T) {
+	r := newRoom("draw-test", RoomOptions{})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.takeSeat(o, "O")
+
+	r.handleOfferDraw(x)
+	if r.game.DrawOffer != "X" {
+		t.Fatalf("expected draw offer from X, got %q", r.game.DrawOffer)
+	}
+
+	r.handleDrawAnswer(o, true)
+	if r.game.Winner != gridwars.WinnerDraw || r.game.Result != gridwars.ResultDraw {
+		t.Errorf("expected a draw, got %q/%q", r.game.Winner, r.game.Result)
+	}
+}
+
+func TestDrawOffer_LapsesWhenOpponentMoves(t *testing.T) {
+	r := newRoom("draw-lapse-test", RoomOptions{})
+	r.takeSeat(&Client{Role: "X"}, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+
+	// X offers on their own turn - still stands after X moves
+	r.game.DrawOffer = "X"
+	r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: "X"})
+	if r.game.DrawOffer != "X" {
+		t.Fatal("offer should stand after the offering player moves")
+	}
+
+	// O moves instead of answering - offer lapses
+	r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: "O"})
+	if r.game.DrawOffer != "" {
+		t.Error("offer should lapse once the opponent takes their turn")
+	}
+}
+
+func TestFreeSeat_MidGameIsAbandonment(t *testing.T) {
+	r := newRoom("abandon-test", RoomOptions{})
+	r.takeSeat(&Client{Role: "X"}, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+
+	// Leaving before anyone moved isn't abandonment
+	r.freeSeat("O")
+	if r.game.Winner != "" {
+		t.Fatalf("expected no result before the game started, got %q", r.game.Winner)
+	}
+
+	r.takeSeat(&Client{Role: "O"}, "O")
+	r.apply(gridwars.Action{Type: gridwars.ActionPass, Mark: "X"})
+	r.freeSeat("O")
+	if r.game.Winner != "X" || r.game.Result != gridwars.ResultAbandoned {
+		t.Errorf("expected X to win by abandonment, got %q/%q", r.game.Winner, r.game.Result)
+	}
+}
+
+func TestCheckWinner_SetsEliminationResult(t *testing.T) {
+	g := newGame(gridwars.Classic)
+	g.Unit("O1").HP = 0
+
+	g.CheckWinner()
+
+	if g.Result != gridwars.ResultElimination {
+		t.Errorf("expected elimination result, got %q", g.Result)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// RotationDelay is how long the final board stays up before winner-stays-on
+// brings in the next challenger
+const RotationDelay = 5 * time.Second
+
+// QueueEntry is one spectator waiting for a seat, in order
+type QueueEntry struct {
+	Name string `json:"name,omitempty"` // Display name (if set)
+}
+
+// handleJoinQueue puts a spectator at the back of the "next up" queue,
+// seating them straight away if a seat is free
+func (r *Room) handleJoinQueue(client *Client) {
+	if client.Role != "spectator" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Only spectators can queue for a seat"})
+		return
+	}
+	if r.queuePosition(client) > 0 {
+		return // Already queued
+	}
+
+	r.queue = append(r.queue, client)
+	if !r.promoteFromQueue() {
+		r.broadcastQueue()
+	}
+	if r.awaitingRotation() {
+		r.cancelProposal() // The next game is the challenger's
+	}
+}
+
+// handleLeaveQueue takes a spectator out of the queue
+func (r *Room) handleLeaveQueue(client *Client) {
+	if r.removeFromQueue(client) {
+		r.broadcastQueue()
+	}
+}
+
+// queuePosition returns a client's 1-based place in the queue, or 0 if not queued
+func (r *Room) queuePosition(client *Client) int {
+	for i, queued := range r.queue {
+		if queued == client {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// removeFromQueue drops a client from the queue, reporting whether they were in it
+func (r *Room) removeFromQueue(client *Client) bool {
+	i := r.queuePosition(client) - 1
+	if i < 0 {
+		return false
+	}
+	r.queue = append(r.queue[:i], r.queue[i+1:]...)
+	return true
+}
+
+// promoteFromQueue seats the head of the queue in any empty seat.
+// Returns true if anyone was promoted.
+func (r *Room) promoteFromQueue() bool {
+	promoted := false
+	for _, mark := range []string{"X", "O"} {
+		if r.seatFor(mark) != nil || len(r.queue) == 0 {
+			continue
+		}
+
+		client := r.queue[0]
+		r.queue = r.queue[1:]
+		r.takeSeat(client, mark)
+		client.Role = mark
+		promoted = true
+
+		sendJSON(client, r.assignedMessage(client))
+		r.broadcastToAll(ServerMessage{
+			Type:    "chat",
+			From:    "system",
+			Message: displayName(client) + " is up next as " + mark,
+		})
+	}
+
+	if promoted {
+		// A new player sitting down at a finished board gets a fresh game
+		if r.game.Winner != "" && r.rotateAt.IsZero() {
+			r.rotateAt = time.Now().Add(RotationDelay)
+			r.rotateOut = nil
+		}
+		r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+		r.broadcastQueue()
+	}
+	return promoted
+}
+
+// checkRotation runs winner-stays-on: once a game is decided and someone is
+// waiting, the loser steps down to the back of the queue after RotationDelay
+// and the next challenger takes their seat for a fresh game. After a draw
+// both players step down, X first, so the queue takes both seats.
+func (r *Room) checkRotation(now time.Time) {
+	if r.game.Winner == "" {
+		r.rotateAt = time.Time{} // Board was reset before the rotation
+		r.rotateOut = nil
+		return
+	}
+
+	if r.rotateAt.IsZero() {
+		if len(r.queue) == 0 {
+			return
+		}
+		r.rotateAt = now.Add(RotationDelay)
+		r.rotateOut = nil
+		for _, mark := range []string{"X", "O"} {
+			if seat := r.seatFor(mark); seat != nil && (r.game.Winner == gridwars.WinnerDraw || r.game.Winner != mark) {
+				r.rotateOut = append(r.rotateOut, seat)
+			}
+		}
+		return
+	}
+	if now.Before(r.rotateAt) {
+		return
+	}
+
+	// Players making way - freeing a seat promotes the head of the queue
+	for _, seat := range r.rotateOut {
+		if r.seatFor(seat.Mark) != seat || len(r.queue) == 0 {
+			continue
+		}
+		r.freeSeat(seat.Mark)
+		if client := seat.Client; client != nil && client.bot == nil {
+			client.Role = "spectator"
+			r.queue = append(r.queue, client)
+			sendJSON(client, r.assignedMessage(client))
+			r.broadcastQueue()
+		}
+	}
+
+	r.rotateAt = time.Time{}
+	r.rotateOut = nil
+	r.resetGame()
+	r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+}
+
+// awaitingRotation reports whether the game is over and someone is queued,
+// so the board goes to winner-stays-on rather than a rematch
+func (r *Room) awaitingRotation() bool {
+	return r.game.Winner != "" && len(r.queue) > 0
+}
+
+// broadcastQueue tells every client the queue order and their own place in it
+func (r *Room) broadcastQueue() {
+	entries := make([]QueueEntry, len(r.queue))
+	for i, client := range r.queue {
+		entries[i] = QueueEntry{Name: client.Name}
+	}
+	for client := range r.clients {
+		sendJSON(client, ServerMessage{Type: "queue", Queue: entries, Position: r.queuePosition(client)})
+	}
+}
+
+// displayName returns a client's name, or their role if they haven't set one
+func displayName(client *Client) string {
+	if client.Name != "" {
+		return client.Name
+	}
+	return client.Role
+}
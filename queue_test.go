@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// newFullRoom returns a room with X and O seated and the given spectators in it
+func newFullRoom(id string, spectators ...*Client) *Room {
+	r := newRoom(id, RoomOptions{})
+	for _, mark := range []string{"X", "O"} {
+		client := &Client{Role: mark}
+		r.clients[client] = true
+		r.takeSeat(client, mark)
+	}
+	for _, client := range spectators {
+		client.Role = "spectator"
+		r.clients[client] = true
+	}
+	return r
+}
+
+func TestQueue_PromotesInOrderWhenSeatEmpties(t *testing.T) {
+	first, second := &Client{Name: "first"}, &Client{Name: "second"}
+	r := newFullRoom("queue-test", first, second)
+
+	r.handleJoinQueue(first)
+	r.handleJoinQueue(second)
+	if r.queuePosition(first) != 1 || r.queuePosition(second) != 2 {
+		t.Fatalf("unexpected queue positions %d, %d", r.queuePosition(first), r.queuePosition(second))
+	}
+
+	r.freeSeat("O")
+
+	if r.seatFor("O") == nil || r.seatFor("O").Client != first || first.Role != "O" {
+		t.Fatal("expected head of queue promoted into seat O")
+	}
+	if r.queuePosition(second) != 1 {
+		t.Errorf("expected second to move up to position 1, got %d", r.queuePosition(second))
+	}
+}
+
+func TestQueue_PlayersCannotQueue(t *testing.T) {
+	r := newFullRoom("queue-player-test")
+	player := r.seatFor("X").Client
+
+	r.handleJoinQueue(player)
+
+	if len(r.queue) != 0 {
+		t.Error("seated player should not be queued")
+	}
+}
+
+func TestRotation_WinnerStaysOn(t *testing.T) {
+	challenger := &Client{Name: "challenger"}
+	r := newFullRoom("rotation-test", challenger)
+	winner := r.seatFor("X").Client
+	loser := r.seatFor("O").Client
+	r.handleJoinQueue(challenger)
+
+	r.game.Unit("O1").HP = 0
+	r.game.CheckWinner()
+
+	now := time.Now()
+	r.checkRotation(now)
+	if r.rotateAt.IsZero() {
+		t.Fatal("expected rotation scheduled after a decided game")
+	}
+
+	r.checkRotation(now.Add(RotationDelay + time.Second))
+
+	if r.seatFor("X").Client != winner {
+		t.Error("winner should keep their seat")
+	}
+	if r.seatFor("O").Client != challenger {
+		t.Error("challenger should take the loser's seat")
+	}
+	if loser.Role != "spectator" || r.queuePosition(loser) != 1 {
+		t.Errorf("loser should go to the back of the queue, got role %q position %d", loser.Role, r.queuePosition(loser))
+	}
+	if r.game.Winner != "" {
+		t.Error("expected a fresh game after rotation")
+	}
+}
+
+func TestRotation_DrawSendsBothPlayersToTheBack(t *testing.T) {
+	challenger := &Client{Name: "challenger"}
+	r := newFullRoom("rotation-draw-test", challenger)
+	x, o := r.seatFor("X").Client, r.seatFor("O").Client
+	r.handleJoinQueue(challenger)
+
+	r.game.Finish(gridwars.WinnerDraw, gridwars.ResultDraw)
+	now := time.Now()
+	r.checkRotation(now)
+	r.checkRotation(now.Add(RotationDelay + time.Second))
+
+	// X stepped down first, so the challenger takes X and X comes straight back as O
+	if r.seatFor("X").Client != challenger || r.seatFor("O").Client != x {
+		t.Error("expected the challenger and then X seated after a draw")
+	}
+	if o.Role != "spectator" || r.queuePosition(o) != 1 {
+		t.Errorf("expected O at the back of the queue, got role %q position %d", o.Role, r.queuePosition(o))
+	}
+}
+
+func TestRotation_RematchRefusedWhileSomeoneIsQueued(t *testing.T) {
+	challenger := &Client{Name: "challenger"}
+	r := newFullRoom("rotation-rematch-test", challenger)
+	x, o := r.seatFor("X").Client, r.seatFor("O").Client
+	r.game.Unit("O1").HP = 0
+	r.game.CheckWinner()
+
+	// A rematch proposed before anyone queued is called off once they do
+	r.handleRematchAction(x)
+	r.handleJoinQueue(challenger)
+	if r.proposal != nil {
+		t.Fatal("expected the rematch called off when a spectator queued")
+	}
+
+	r.handleRematchAction(o)
+	if r.proposal != nil {
+		t.Error("expected a rematch refused while someone is queued")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+
+	"go-multiplayer/gridwars"
+)
+
+// Kinds of proposal a player can make to start the board over
+const (
+	ProposalRematch = "rematch" // Game is over - play again
+	ProposalReset   = "reset"   // Game still in progress - abandon it and start over
+)
+
+// Proposal is a pending rematch/reset waiting on the opponent's answer
+type Proposal struct {
+	From string `json:"from"` // "X" or "O"
+	Kind string `json:"kind"` // ProposalRematch or ProposalReset
+}
+
+// ErrRotationPending is returned for a rematch while spectators are queued -
+// the next game goes to winner-stays-on instead
+var ErrRotationPending = errors.New("Someone is waiting for a seat - the next game is theirs")
+
+// handleRematchAction lets a player propose starting over. The opponent has
+// to agree, unless their seat is empty and there's nobody to ask.
+func (r *Room) handleRematchAction(client *Client) {
+	if client.Role != "X" && client.Role != "O" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot reset the game"})
+		return
+	}
+	if r.awaitingRotation() {
+		sendJSON(client, ServerMessage{Type: "error", Error: ErrRotationPending.Error()})
+		return
+	}
+
+	opponent := gridwars.Other(client.Role)
+	if r.seatFor(opponent) == nil {
+		r.proposal = nil
+		r.startRematch()
+		return
+	}
+
+	// Opponent already asked - proposing back counts as agreeing
+	if r.proposal != nil && r.proposal.From == opponent {
+		r.acceptProposal()
+		return
+	}
+
+	kind := ProposalReset
+	if r.game.Winner != "" {
+		kind = ProposalRematch
+	}
+	r.proposal = &Proposal{From: client.Role, Kind: kind}
+	r.broadcastToAll(ServerMessage{Type: "rematch_proposed", Proposal: r.proposal})
+}
+
+// handleRematchAnswer handles the opponent accepting or declining a proposal
+func (r *Room) handleRematchAnswer(client *Client, accept bool) {
+	if client.Role != "X" && client.Role != "O" {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Spectators cannot answer a rematch"})
+		return
+	}
+	if r.proposal == nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: "No rematch has been proposed"})
+		return
+	}
+	if r.proposal.From == client.Role {
+		sendJSON(client, ServerMessage{Type: "error", Error: "Waiting for your opponent to answer"})
+		return
+	}
+
+	if accept && r.awaitingRotation() {
+		sendJSON(client, ServerMessage{Type: "error", Error: ErrRotationPending.Error()})
+		r.cancelProposal()
+		return
+	}
+	if accept {
+		r.acceptProposal()
+		return
+	}
+
+	proposal := r.proposal
+	r.proposal = nil
+	r.broadcastToAll(ServerMessage{Type: "rematch_declined", Proposal: proposal})
+}
+
+// acceptProposal clears the pending proposal and starts the new game
+func (r *Room) acceptProposal() {
+	r.proposal = nil
+	r.startRematch()
+}
+
+// cancelProposal drops a pending proposal involving a player who gave up their seat
+func (r *Room) cancelProposal() {
+	if r.proposal == nil {
+		return
+	}
+	proposal := r.proposal
+	r.proposal = nil
+	r.broadcastToAll(ServerMessage{Type: "rematch_declined", Proposal: proposal})
+}
+
+// sendProposal tells a (re)joining client about a pending proposal
+func (r *Room) sendProposal(client *Client) {
+	if r.proposal != nil {
+		sendJSON(client, ServerMessage{Type: "rematch_proposed", Proposal: r.proposal})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestRematch_NeedsOpponentToAccept(t *testing.T) {
+	r := newRoom("rematch-test", RoomOptions{})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.takeSeat(o, "O")
+	r.game.Unit("O1").HP = 0
+	r.game.CheckWinner()
+
+	r.handleRematchAction(x)
+	if r.proposal == nil || r.proposal.Kind != ProposalRematch || r.proposal.From != "X" {
+		t.Fatalf("expected rematch proposal from X, got %+v", r.proposal)
+	}
+	if r.game.Winner == "" {
+		t.Fatal("game reset before opponent agreed")
+	}
+
+	r.handleRematchAnswer(o, true)
+	if r.proposal != nil {
+		t.Error("expected proposal cleared after accepting")
+	}
+	if r.game.Winner != "" || r.game.Unit("O1").HP != gridwars.Classic.MaxHP {
+		t.Error("expected a fresh game after accepting")
+	}
+	if r.seatFor("X").Client != o || r.seatFor("O").Client != x {
+		t.Error("expected players to swap seats on rematch")
+	}
+}
+
+func TestRematch_DeclineKeepsGame(t *testing.T) {
+	r := newRoom("decline-test", RoomOptions{})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.takeSeat(o, "O")
+	r.game.Unit("X1").HP = 4
+
+	r.handleRematchAction(o)
+	if r.proposal == nil || r.proposal.Kind != ProposalReset {
+		t.Fatalf("expected reset proposal mid-game, got %+v", r.proposal)
+	}
+
+	r.handleRematchAnswer(x, false)
+	if r.proposal != nil {
+		t.Error("expected proposal cleared after declining")
+	}
+	if r.game.Unit("X1").HP != 4 {
+		t.Error("game should not reset when declined")
+	}
+}
+
+func TestRematch_EmptyOpponentSeatResetsImmediately(t *testing.T) {
+	r := newRoom("solo-reset-test", RoomOptions{})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.game.Unit("X1").HP = 2
+
+	r.handleRematchAction(x)
+	if r.proposal != nil || r.game.Unit("X1").HP != gridwars.Classic.MaxHP {
+		t.Error("expected immediate reset with nobody to ask")
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// Kinds of event recorded in a game's log
+const (
+	EventStart  = "start"  // Game began (state is the starting board)
+	EventMove   = "move"   // Unit moved to x,y
+	EventPickup = "pickup" // Unit collected a power-up
+	EventSpawn  = "spawn"  // Power-up appeared
+	EventAttack = "attack" // Attack declared on x,y
+	EventDice   = "dice"   // Server pre-rolled both dice (hidden from players at the time)
+	EventRoll   = "roll"   // Combatant's die revealed
+	EventCombat = "combat" // Combat resolved and damage applied
+	EventPass   = "pass"   // Turn passed on time
+	EventPick   = "pick"   // Class drafted (text is the class, random if it was drawn from the pool)
+	EventChat   = "chat"   // Chat message
+	EventEnd    = "end"    // Game decided (mark is the winner, text the Result)
+)
+
+// Event is one timestamped entry in a game's log. The last event each
+// action logs that changes the board carries the full state it left the
+// game in, so a replay can jump to any step by showing the latest state at
+// or before it.
+type Event struct {
+	Seq     int               `json:"seq"`
+	Time    int64             `json:"time"` // Unix ms
+	Type    string            `json:"type"` // Event* constants
+	Mark    string            `json:"mark,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Unit    string            `json:"unit,omitempty"` // Unit that moved, picked up or attacked
+	X       int               `json:"x"`
+	Y       int               `json:"y"`
+	Roll    int               `json:"roll,omitempty"`
+	Hidden  bool              `json:"hidden,omitempty"` // Not shown to players when it happened
+	Random  bool              `json:"random,omitempty"` // Pick: drawn at random, so replaying it draws from the seed too
+	Text    string            `json:"text,omitempty"`
+	Room    string            `json:"room,omitempty"`
+	Private bool              `json:"private,omitempty"` // Start: played in a private room
+	Players []PlayerInfo      `json:"players,omitempty"`
+	Seed    uint64            `json:"seed,omitempty"` // Start: the game's RNG seed
+	Combat  *CombatResult     `json:"combat,omitempty"`
+	PowerUp *gridwars.PowerUp `json:"powerUp,omitempty"`
+	State   json.RawMessage   `json:"state,omitempty"`
+}
+
+// EventLog is an append-only record of every game's events, keyed by game ID
+type EventLog interface {
+	Append(gameID string, event Event) error
+	Events(gameID string) ([]Event, error) // ErrReplayNotFound if never logged
+	Games() ([]string, error)              // Logged game IDs, oldest first
+}
+
+// eventLog records every game (in memory unless STATE_DIR is set)
+var eventLog EventLog = newMemoryLog()
+
+// GameIDBytes is how many random bytes go into a game ID
+const GameIDBytes = 8
+
+// Errors returned when fetching a replay
+var (
+	ErrReplayNotFound    = errors.New("Replay not found")
+	ErrReplayNotFinished = errors.New("That game isn't finished yet")
+)
+
+// beginLog starts a new log for the room's current game. Nothing is written
+// until the first event, so games nobody plays don't clutter the log.
+// Only called from the room's goroutine (or before it starts).
+func (r *Room) beginLog() {
+	r.game.ID = randomID(GameIDBytes)
+	r.logSeq = 0
+	r.logStart = r.stateJSON()
+	r.logChat = nil
+}
+
+// resumeLog picks up the log of a game restored from a snapshot
+func (r *Room) resumeLog() {
+	if r.game.ID == "" {
+		r.beginLog()
+		return
+	}
+	events, _ := eventLog.Events(r.game.ID)
+	r.logSeq = len(events)
+	r.logStart = nil
+	if r.game.Winner != "" {
+		r.logEnded = r.game.ID
+	}
+}
+
+// stateJSON captures the game state as it is right now
+func (r *Room) stateJSON() json.RawMessage {
+	data, err := json.Marshal(r.game)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// record appends an event to the current game's log
+func (r *Room) record(e Event) {
+	if r.logSeq == 0 {
+		if r.logStart == nil {
+			r.logStart = r.stateJSON() // Restored game without a saved start
+		}
+		r.appendEvent(Event{Type: EventStart, Room: r.ID, Private: r.private, Players: r.Info().Players, Seed: r.seed, State: r.logStart})
+		r.logStart = nil
+		for _, chat := range r.logChat {
+			r.appendEvent(chat)
+		}
+		r.logChat = nil
+	}
+	if e.Combat != nil {
+		combat := *e.Combat // Combat keeps changing after it's logged
+		e.Combat = &combat
+	}
+	r.appendEvent(e)
+}
+
+// recordState appends an event along with the state it left the game in
+func (r *Room) recordState(e Event) {
+	e.State = r.stateJSON()
+	r.record(e)
+}
+
+func (r *Room) appendEvent(e Event) {
+	e.Seq = r.logSeq
+	e.Time = time.Now().UnixMilli()
+	r.logSeq++
+	if err := eventLog.Append(r.game.ID, e); err != nil {
+		fmt.Println("Event log error:", err)
+	}
+}
+
+// recordChat logs chat until the game is decided. Chat from before the
+// first move waits for the log to start, so the start still lists whoever
+// ended up playing.
+func (r *Room) recordChat(client *Client, text string) {
+	if r.game.Winner != "" {
+		return
+	}
+	e := Event{Type: EventChat, Mark: client.Role, Name: client.Name, Text: text}
+	if r.logSeq == 0 {
+		r.logChat = append(r.logChat, e)
+		return
+	}
+	r.record(e)
+}
+
+// recordEnd closes the log and rates the game once it's decided
+func (r *Room) recordEnd() {
+	if r.game.Winner == "" || r.logEnded == r.game.ID {
+		return
+	}
+	r.logEnded = r.game.ID
+	r.recordState(Event{Type: EventEnd, Mark: r.game.Winner, Text: r.game.Result})
+	r.rateGame()
+}
+
+// loadReplay returns the events of a finished game
+func loadReplay(gameID string) ([]Event, error) {
+	events, err := eventLog.Events(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 || events[len(events)-1].Type != EventEnd {
+		return nil, ErrReplayNotFinished
+	}
+	return events, nil
+}
+
+// streamReplay sends a finished game to a client one "replay" message per event
+func streamReplay(client *Client, gameID string) {
+	events, err := loadReplay(gameID)
+	if err != nil {
+		sendJSON(client, ServerMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	for i := range events {
+		sendJSON(client, ServerMessage{Type: "replay", GameID: gameID, Event: &events[i]})
+	}
+}
+
+// ReplayInfo summarises a finished game for the replay list
+type ReplayInfo struct {
+	ID      string       `json:"id"`
+	Room    string       `json:"room"`
+	Players []PlayerInfo `json:"players"`
+	Winner  string       `json:"winner"`
+	Result  string       `json:"result"`
+	Started int64        `json:"started"` // Unix ms
+	Ended   int64        `json:"ended"`   // Unix ms
+	Events  int          `json:"events"`
+}
+
+// MaxReplayList is how many recent games /replays lists
+const MaxReplayList = 50
+
+// listReplays summarises the most recent finished games from public rooms,
+// newest first. Private rooms' games are left out, but anyone with a game's
+// ID (which can't be guessed) can still watch it.
+func listReplays() []ReplayInfo {
+	ids, err := eventLog.Games()
+	if err != nil {
+		fmt.Println("Event log error:", err)
+	}
+	list := []ReplayInfo{}
+	for i := len(ids) - 1; i >= 0 && len(list) < MaxReplayList; i-- {
+		events, err := loadReplay(ids[i])
+		if err != nil || events[0].Private {
+			continue
+		}
+		start, end := events[0], events[len(events)-1]
+		list = append(list, ReplayInfo{
+			ID:      ids[i],
+			Room:    start.Room,
+			Players: start.Players,
+			Winner:  end.Mark,
+			Result:  end.Text,
+			Started: start.Time,
+			Ended:   end.Time,
+			Events:  len(events),
+		})
+	}
+	return list
+}
+
+// handleReplays serves GET /replays (recent finished games) and
+// GET /replays?game=<id> (every event of one game)
+func handleReplays(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	gameID := req.URL.Query().Get("game")
+	if gameID == "" {
+		json.NewEncoder(w).Encode(listReplays())
+		return
+	}
+
+	events, err := loadReplay(gameID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"id": gameID, "events": events})
+}
+
+// MaxLoggedGames is how many games the in-memory log keeps before dropping the oldest
+const MaxLoggedGames = 200
+
+// memoryLog keeps recent games in memory (lost on restart)
+type memoryLog struct {
+	mu     sync.Mutex
+	order  []string
+	events map[string][]Event
+}
+
+func newMemoryLog() *memoryLog {
+	return &memoryLog{events: make(map[string][]Event)}
+}
+
+func (l *memoryLog) Append(gameID string, event Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.events[gameID]; !ok {
+		l.order = append(l.order, gameID)
+		if len(l.order) > MaxLoggedGames {
+			delete(l.events, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	l.events[gameID] = append(l.events[gameID], event)
+	return nil
+}
+
+func (l *memoryLog) Events(gameID string) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events, ok := l.events[gameID]
+	if !ok {
+		return nil, ErrReplayNotFound
+	}
+	return append([]Event(nil), events...), nil
+}
+
+func (l *memoryLog) Games() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.order...), nil
+}
+
+// FileLog appends each game's events to its own JSON Lines file
+type FileLog struct {
+	Dir string
+	mu  sync.Mutex // Serialises appends from different rooms
+}
+
+// newFileLog creates the directory if needed
+func newFileLog(dir string) (*FileLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileLog{Dir: dir}, nil
+}
+
+// path returns the file for a game, or "" if the ID isn't one we'd generate
+func (l *FileLog) path(gameID string) string {
+	if _, err := hex.DecodeString(gameID); err != nil || gameID == "" {
+		return ""
+	}
+	return filepath.Join(l.Dir, gameID+".jsonl")
+}
+
+func (l *FileLog) Append(gameID string, event Event) error {
+	path := l.path(gameID)
+	if path == "" {
+		return ErrReplayNotFound
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *FileLog) Events(gameID string) ([]Event, error) {
+	path := l.path(gameID)
+	if path == "" {
+		return nil, ErrReplayNotFound
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReplayNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			break // Torn final line from a crash mid-append
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+func (l *FileLog) Games() ([]string, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, err
+	}
+	type game struct {
+		id       string
+		modified time.Time
+	}
+	var games []game
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || l.path(id) == "" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		games = append(games, game{id, info.ModTime()})
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].modified.Before(games[j].modified) })
+
+	ids := make([]string, len(games))
+	for i, g := range games {
+		ids[i] = g.id
+	}
+	return ids, nil
+}
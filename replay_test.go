@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestReplay_RecordsGameUntilFinished(t *testing.T) {
+	rules := gridwars.Classic
+	rules.PowerUpChance = 0 // Keep the move the last step it logs
+	r := newRoom("replay-test", RoomOptions{Rules: rules})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+	gameID := r.game.ID
+
+	r.handleAction(Action{Type: ActionMove, Client: x, X: 1, Y: gridwars.Classic.BoardSize - 2})
+	if _, err := loadReplay(gameID); err != ErrReplayNotFinished {
+		t.Fatalf("expected unfinished game to be hidden, got %v", err)
+	}
+
+	r.handleAction(Action{Type: ActionResign, Client: x})
+
+	events, err := loadReplay(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].Type != EventStart || events[1].Type != EventMove || events[len(events)-1].Type != EventEnd {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for i, e := range events {
+		if e.Seq != i {
+			t.Errorf("event %d has seq %d", i, e.Seq)
+		}
+	}
+
+	var start, moved Game
+	json.Unmarshal(events[0].State, &start)
+	json.Unmarshal(events[1].State, &moved)
+	if start.Unit("X1").X != 0 || moved.Unit("X1").X != 1 || moved.Unit("X1").Y != gridwars.Classic.BoardSize-2 {
+		t.Error("expected states before and after the move")
+	}
+	if end := events[len(events)-1]; end.Mark != "O" || end.Text != gridwars.ResultResignation {
+		t.Errorf("unexpected end event %+v", end)
+	}
+	if events[0].Seed != r.seed {
+		t.Error("expected the start event to record the game's seed")
+	}
+
+	// The next game gets its own log
+	r.resetGame()
+	if r.game.ID == gameID {
+		t.Error("expected a new game ID after reset")
+	}
+}
+
+func TestReplay_StateOnlyOnActionsLastStep(t *testing.T) {
+	rules := gridwars.Classic
+	rules.PowerUpChance = 100
+	r := newRoom("replay-steps-test", RoomOptions{Rules: rules})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+	gameID := r.game.ID
+
+	r.handleAction(Action{Type: ActionMove, Client: x, X: 1, Y: gridwars.Classic.BoardSize - 2})
+	r.handleAction(Action{Type: ActionResign, Client: x})
+
+	events, err := loadReplay(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move, spawn := events[1], events[2]
+	if move.Type != EventMove || spawn.Type != EventSpawn {
+		t.Fatalf("expected a move then a spawn, got %+v", events)
+	}
+	if move.State != nil {
+		t.Error("expected the move logged without the state the spawn left")
+	}
+	var spawned Game
+	json.Unmarshal(spawn.State, &spawned)
+	if len(spawned.PowerUps) != 1 || spawned.Unit("X1").X != 1 {
+		t.Errorf("expected the spawn to show the whole action, got %s", spawn.State)
+	}
+}
+
+func TestReplay_DiceLoggedHiddenThenRevealed(t *testing.T) {
+	r := newRoom("replay-dice-test", RoomOptions{})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.takeSeat(o, "O")
+	placeUnit(r.game, "O1", 1, gridwars.Classic.BoardSize-2)
+	gameID := r.game.ID
+
+	r.handleAttackAction(x, "", 1, gridwars.Classic.BoardSize-2)
+	combat := *r.game.Combat
+	r.handleRollAction(x)
+	r.handleRollAction(o)
+
+	events, _ := eventLog.Events(gameID)
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{EventStart, EventAttack, EventDice, EventRoll, EventRoll, EventCombat}
+	for i, typ := range want {
+		if i >= len(types) || types[i] != typ {
+			t.Fatalf("expected events to start %v, got %v", want, types)
+		}
+	}
+
+	dice := events[2]
+	if !dice.Hidden || dice.Combat.AttackerRoll != combat.AttackerRoll || dice.Combat.DefenderRoll != combat.DefenderRoll {
+		t.Errorf("expected hidden pre-roll, got %+v", dice)
+	}
+	if dice.Combat.AttackerRolled {
+		t.Error("logged combat should not change after it's recorded")
+	}
+	if events[3].Roll != combat.AttackerRoll || events[4].Roll != combat.DefenderRoll {
+		t.Error("expected each revealed roll logged")
+	}
+}
+
+func TestFileLog_AppendAndList(t *testing.T) {
+	log, err := newFileLog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Append("aa01", Event{Seq: 0, Type: EventStart})
+	log.Append("aa01", Event{Seq: 1, Type: EventEnd})
+	if err := log.Append("../escape", Event{}); err == nil {
+		t.Error("expected non-hex game ID to be rejected")
+	}
+
+	events, err := log.Events("aa01")
+	if err != nil || len(events) != 2 || events[1].Type != EventEnd {
+		t.Fatalf("unexpected events %+v (%v)", events, err)
+	}
+	if _, err := log.Events("bb02"); err != ErrReplayNotFound {
+		t.Errorf("expected ErrReplayNotFound, got %v", err)
+	}
+	if ids, _ := log.Games(); len(ids) != 1 || ids[0] != "aa01" {
+		t.Errorf("unexpected games %v", ids)
+	}
+}
+
+func TestReplay_KeepsChatFromBeforeTheFirstMove(t *testing.T) {
+	r := newRoom("replay-chat-test", RoomOptions{})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.recordChat(x, "anyone?")
+	r.takeSeat(o, "O")
+	r.recordChat(o, "hi")
+	gameID := r.game.ID
+
+	r.handleAction(Action{Type: ActionMove, Client: x, X: 1, Y: gridwars.Classic.BoardSize - 2})
+	r.handleAction(Action{Type: ActionResign, Client: x})
+
+	events, err := loadReplay(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[1].Text != "anyone?" || events[2].Text != "hi" || events[3].Type != EventMove {
+		t.Errorf("expected the early chat logged after the start, got %+v", events[1:4])
+	}
+}
+
+func TestReplay_PrivateGamesUnlistedButServedByID(t *testing.T) {
+	r := newRoom("replay-private-test", RoomOptions{Private: true})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+	gameID := r.game.ID
+	r.handleAction(Action{Type: ActionMove, Client: x, X: 1, Y: gridwars.Classic.BoardSize - 2})
+	r.handleAction(Action{Type: ActionResign, Client: x})
+
+	for _, game := range listReplays() {
+		if game.ID == gameID {
+			t.Error("expected the private game left out of the list")
+		}
+	}
+	w := httptest.NewRecorder()
+	handleReplays(w, httptest.NewRequest("GET", "/replays?game="+gameID, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the private game still served by ID, got %d", w.Code)
+	}
+}
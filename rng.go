@@ -0,0 +1,26 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+// seedGame gives the current game its own random source. Dice and power-up
+// spawns only draw from it, so the seed plus the event log is enough to
+// re-simulate a game exactly. The seed is never sent to players - it would
+// give away the hidden dice.
+func (r *Room) seedGame(seed uint64) {
+	r.seed = seed
+	r.pcg = rand.NewPCG(seed, seed)
+	r.rng = rand.New(r.pcg)
+}
+
+// randomSeed picks an unpredictable seed for a new game
+func randomSeed() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"go-multiplayer/ai"
+	"go-multiplayer/gridwars"
+)
+
+// newSeededCombatRoom returns a room with both players seated next to each
+// other, its game seeded with seed
+func newSeededCombatRoom(id string, seed uint64) (*Room, *Client) {
+	r := newRoom(id, RoomOptions{})
+	x := &Client{Role: "X"}
+	r.takeSeat(x, "X")
+	r.takeSeat(&Client{Role: "O"}, "O")
+	placeUnit(r.game, "O1", 1, gridwars.Classic.BoardSize-2)
+	r.seedGame(seed)
+	return r, x
+}
+
+func TestSeedGame_SameSeedSameDice(t *testing.T) {
+	a, ax := newSeededCombatRoom("seed-a", 42)
+	b, bx := newSeededCombatRoom("seed-b", 42)
+
+	a.handleAttackAction(ax, "", 1, gridwars.Classic.BoardSize-2)
+	b.handleAttackAction(bx, "", 1, gridwars.Classic.BoardSize-2)
+
+	ca, cb := a.game.Combat, b.game.Combat
+	if ca.AttackerRoll != cb.AttackerRoll || ca.DefenderRoll != cb.DefenderRoll {
+		t.Errorf("same seed rolled %d-%d and %d-%d", ca.AttackerRoll, ca.DefenderRoll, cb.AttackerRoll, cb.DefenderRoll)
+	}
+}
+
+func TestSeedGame_SameSeedSameSpawns(t *testing.T) {
+	a := newRoom("spawn-a", RoomOptions{})
+	b := newRoom("spawn-b", RoomOptions{})
+	a.seedGame(7)
+	b.seedGame(7)
+
+	// Shuffle both units back and forth - every move rolls for a spawn
+	for i := 0; i < 20; i++ {
+		step := (i + 1) % 2
+		for _, r := range []*Room{a, b} {
+			if err := r.apply(gridwars.Action{Type: gridwars.ActionMove, Mark: "X", X: step, Y: gridwars.Classic.BoardSize - 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.apply(gridwars.Action{Type: gridwars.ActionMove, Mark: "O", X: gridwars.Classic.BoardSize - 1 - step, Y: 0}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if len(a.game.PowerUps) == 0 {
+		t.Fatal("expected some power-ups in 40 moves")
+	}
+	if len(a.game.PowerUps) != len(b.game.PowerUps) {
+		t.Fatalf("same seed spawned %d and %d power-ups", len(a.game.PowerUps), len(b.game.PowerUps))
+	}
+	for i := range a.game.PowerUps {
+		if a.game.PowerUps[i] != b.game.PowerUps[i] {
+			t.Errorf("power-up %d differs: %+v vs %+v", i, a.game.PowerUps[i], b.game.PowerUps[i])
+		}
+	}
+}
+
+func TestSeedGame_ResetPicksNewSeed(t *testing.T) {
+	r := newRoom("reseed-test", RoomOptions{})
+	seed := r.seed
+	r.resetGame()
+	if r.seed == seed {
+		t.Error("expected a fresh seed for the next game")
+	}
+}
+
+func TestRestoreRoom_RandomSourceCarriesOn(t *testing.T) {
+	r, _ := newSeededCombatRoom("rng-restore-test", 99)
+	r.rng.IntN(6) // Part way through the game's rolls
+
+	data, _ := json.Marshal(r.snapshot())
+	restored, err := restoreRoom(data, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.seed != 99 {
+		t.Errorf("expected seed 99, got %d", restored.seed)
+	}
+	for i := 0; i < 10; i++ {
+		if want, got := r.rng.IntN(1000), restored.rng.IntN(1000); want != got {
+			t.Fatalf("draw %d: expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+// rebuildGame re-simulates a logged game from its seed: the start event's
+// board, then every action the log records, drawing from a fresh random
+// source seeded the same way as the room's
+func rebuildGame(t *testing.T, events []Event) gridwars.State {
+	var start Game
+	if err := json.Unmarshal(events[0].State, &start); err != nil {
+		t.Fatal(err)
+	}
+	r := newRoom("rebuild", RoomOptions{Rules: start.Rules})
+	r.seedGame(events[0].Seed)
+
+	state := start.State
+	for _, e := range events[1:] {
+		var action gridwars.Action
+		switch e.Type {
+		case EventPick:
+			action = gridwars.Action{Type: gridwars.ActionPick, Mark: e.Mark, Class: e.Text}
+			if e.Random {
+				action.Class = ""
+			}
+		case EventMove:
+			action = gridwars.Action{Type: gridwars.ActionMove, Mark: e.Mark, Unit: e.Unit, X: e.X, Y: e.Y}
+		case EventAttack:
+			action = gridwars.Action{Type: gridwars.ActionAttack, Mark: e.Mark, Unit: e.Unit, X: e.X, Y: e.Y}
+		case EventRoll:
+			action = gridwars.Action{Type: gridwars.ActionRoll, Mark: e.Mark}
+		case EventPass:
+			action = gridwars.Action{Type: gridwars.ActionPass, Mark: e.Mark}
+		case EventEnd:
+			if state.Winner == "" {
+				state.Finish(e.Mark, e.Text)
+			}
+			continue
+		default:
+			continue // Start, chat, and what the rules did in response to an action
+		}
+		next, _, err := gridwars.Apply(state, action, r.rng)
+		if err != nil {
+			t.Fatalf("event %d (%s): %v", e.Seq, e.Type, err)
+		}
+		state = next
+	}
+	return state
+}
+
+func TestSeedGame_RebuildsGameFromLog(t *testing.T) {
+	r := newRoom("rebuild-test", RoomOptions{Rules: gridwars.Skirmish})
+	x, o := &Client{Role: "X"}, &Client{Role: "O"}
+	r.takeSeat(x, "X")
+	r.takeSeat(o, "O")
+	clients := map[string]*Client{"X": x, "O": o}
+
+	// Half the picks left to the draw
+	for i := 0; r.game.Draft != nil; i++ {
+		class := ""
+		if i%2 == 0 {
+			class = r.game.Draft.Available()[0]
+		}
+		r.handlePick(clients[r.game.Draft.Turn], class)
+	}
+
+	strategy, _ := ai.ForLevel("medium", rand.New(rand.NewPCG(1, 2)))
+	for i := 0; i < 300 && r.game.Winner == ""; i++ {
+		mark := r.game.Turn
+		if combat := r.game.Combat; combat != nil {
+			mark = combat.AttackerMark
+			if combat.AttackerRolled {
+				mark = combat.DefenderMark
+			}
+		}
+		action, ok := strategy.Choose(r.game.State, mark)
+		if !ok {
+			t.Fatalf("%s had nothing to do", mark)
+		}
+		if err := r.apply(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.game.Winner == "" {
+		r.handleResign(o)
+	}
+	r.recordEnd()
+
+	events, err := loadReplay(r.game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.Type] = true
+		if e.Random {
+			seen["random pick"] = true
+		}
+	}
+	for _, want := range []string{"random pick", EventSpawn, EventCombat} {
+		if !seen[want] {
+			t.Fatalf("expected the game to have a %s", want)
+		}
+	}
+
+	rebuilt, err := json.Marshal(rebuildGame(t, events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	played, _ := json.Marshal(r.game.State)
+	if string(rebuilt) != string(played) {
+		t.Errorf("rebuilt game differs from the one played:\n%s\n%s", rebuilt, played)
+	}
+}
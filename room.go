@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	mrand "math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// DefaultRoomID is the always-open room, so the lobby is never empty
+const DefaultRoomID = "main"
+
+// MaxRoomIDLength limits how long a room ID can be
+const MaxRoomIDLength = 32
+
+// Room is an independent game with its own players, spectators and manager goroutine
+type Room struct {
+	ID            string
+	game          *Game            // Only touched by this room's goroutine
+	pendingCombat *PendingCombat   // Set when combat starts, cleared when both roll
+	pickDeadline  time.Time        // When the server picks for whoever's drafting (zero = not started)
+	proposal      *Proposal        // Pending rematch/reset waiting on the opponent
+	queue         []*Client        // Spectators waiting for a seat, in order
+	rotateAt      time.Time        // When winner-stays-on brings in the next player (zero = not scheduled)
+	rotateOut     []*Seat          // Seats that step down at rotateAt (none = just start a fresh game)
+	clients       map[*Client]bool // Clients currently in this room
+	seats         map[string]*Seat // Player seats by mark, kept across disconnects
+	actions       chan Action      // All actions for this room go here
+	closing       bool             // Set by the room's goroutine when it removed itself
+	timeControl   TimeControl      // How long players get to move
+	rules         gridwars.Rules   // What every game in this room is played by
+
+	persisted  bool   // Snapshots go to the store, so held seats can outlast a restart
+	private    bool   // Private rooms need inviteCode to join
+	inviteCode string // Set at creation and never changed
+
+	members int             // Clients routed to this room, guarded by roomsMu
+	held    map[string]bool // Session tokens of seats held for disconnected players, guarded by roomsMu
+	tokens  map[string]bool // Session tokens of every seat, held or not, guarded by roomsMu
+
+	seed uint64      // Seed the current game's random source started from
+	pcg  *mrand.PCG  // State of rng, saved in snapshots
+	rng  *mrand.Rand // All of the game's randomness comes from here
+
+	logSeq   int             // Events logged so far for the current game
+	logStart json.RawMessage // Starting state, logged with the first event
+	logChat  []Event         // Chat from before the first event, logged right after the start
+	logEnded string          // ID of the last game whose end was logged
+
+	saved   []byte    // Last snapshot written to the store
+	savedAt time.Time // When the snapshot was last checked
+
+	infoMu sync.Mutex // Guards info
+	info   RoomInfo   // Lobby summary, republished after every action
+}
+
+// RoomInfo is the lobby's view of a room
+type RoomInfo struct {
+	ID         string       `json:"id"`
+	Players    []PlayerInfo `json:"players"`    // Seated players (X and/or O)
+	Spectators int          `json:"spectators"` // Number of spectators watching
+	Queued     int          `json:"queued"`     // Spectators waiting for a seat
+	Turn       string       `json:"turn"`       // "X" or "O"
+	Winner     string       `json:"winner"`     // "", "X", "O", or "draw"
+	Private    bool         `json:"private"`    // Joining needs an invite code
+	Clock      string       `json:"clock"`      // Time control, e.g. "none" or "clock:300+5"
+	Rules      string       `json:"rules"`      // Rule preset, e.g. "classic"
+}
+
+// PlayerInfo describes a seated player in a room listing
+type PlayerInfo struct {
+	Mark string `json:"mark"`           // "X" or "O"
+	Name string `json:"name,omitempty"` // Display name (if set)
+	Away bool   `json:"away,omitempty"` // Disconnected, seat held for them
+	Bot  bool   `json:"bot,omitempty"`  // Played by the server
+
+	Rating int `json:"rating,omitempty"` // Signed-in player's rating
+}
+
+// Registry of live rooms. The mutex only guards the map and member counts -
+// game state inside each room is still owned by that room's goroutine.
+var (
+	rooms   = make(map[string]*Room)
+	roomsMu sync.Mutex
+)
+
+// newRoom creates a room with a fresh game (does not start its goroutine)
+func newRoom(id string, opts RoomOptions) *Room {
+	if opts.Rules.BoardSize == 0 {
+		opts.Rules = gridwars.Classic
+	}
+	r := &Room{
+		ID:          id,
+		game:        newGame(opts.Rules),
+		clients:     make(map[*Client]bool),
+		seats:       make(map[string]*Seat),
+		actions:     make(chan Action),
+		timeControl: opts.TimeControl,
+		rules:       opts.Rules,
+		held:        make(map[string]bool),
+		tokens:      make(map[string]bool),
+		persisted:   store != nil,
+	}
+	r.game.Clock = newClock(opts.TimeControl)
+	r.seedGame(randomSeed())
+	r.beginLog()
+	if opts.Private {
+		r.private = true
+		r.inviteCode = opts.Password
+		if r.inviteCode == "" {
+			r.inviteCode = randomID(InviteCodeBytes)
+		}
+	}
+	r.publishInfo()
+	return r
+}
+
+// Info returns the room's latest lobby summary (safe from any goroutine)
+func (r *Room) Info() RoomInfo {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	return r.info
+}
+
+// publishInfo rebuilds the lobby summary from the game state.
+// Only called from the room's goroutine (or before it starts).
+func (r *Room) publishInfo() {
+	info := RoomInfo{
+		ID:      r.ID,
+		Players: []PlayerInfo{},
+		Turn:    r.game.Turn,
+		Winner:  r.game.Winner,
+		Private: r.private,
+		Clock:   r.timeControl.String(),
+		Rules:   r.rules.Name,
+	}
+	for _, seat := range r.seats {
+		player := PlayerInfo{Mark: seat.Mark, Name: seat.Name, Away: seat.Client == nil, Bot: seat.Client != nil && seat.Client.isBot()}
+		if account, ok := accounts.Lookup(r.game.Accounts[seat.Mark]); ok {
+			player.Rating = account.Rating
+		}
+		info.Players = append(info.Players, player)
+	}
+	for client := range r.clients {
+		if client.Role == "spectator" {
+			info.Spectators++
+		}
+	}
+	info.Queued = len(r.queue)
+	sort.Slice(info.Players, func(i, j int) bool { return info.Players[i].Mark < info.Players[j].Mark })
+
+	r.infoMu.Lock()
+	r.info = info
+	r.infoMu.Unlock()
+}
+
+// normalizeRoomID cleans up a requested room ID, falling back to the default room
+func normalizeRoomID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return DefaultRoomID
+	}
+	if len(id) > MaxRoomIDLength {
+		id = id[:MaxRoomIDLength]
+	}
+	return id
+}
+
+// Errors returned when routing a client into a room
+var (
+	ErrRoomNotFound = errors.New("Room not found")
+	ErrRoomExists   = errors.New("Room already exists")
+	ErrRoomFull     = errors.New("Room full (max 10 players)")
+	ErrInviteCode   = errors.New("This room is private - a valid invite code is required")
+)
+
+// InviteCodeBytes is how many random bytes go into a private room's invite code
+const InviteCodeBytes = 12
+
+// registerRoom adds a room to the registry and starts its goroutine.
+// Caller must hold roomsMu.
+func registerRoom(room *Room) *Room {
+	rooms[room.ID] = room
+	go room.run()
+	return room
+}
+
+// reserve takes a place in the room for a client presenting an invite code
+// (ignored for public rooms). A seat's session token gets in regardless,
+// since that player was already admitted. Caller must hold roomsMu.
+func (r *Room) reserve(code, token string) error {
+	resuming := token != "" && r.tokens[token]
+	if r.private && !resuming && subtle.ConstantTimeCompare([]byte(code), []byte(r.inviteCode)) != 1 {
+		return ErrInviteCode
+	}
+	if r.members >= MaxClients && !resuming {
+		return ErrRoomFull
+	}
+	r.members++
+	return nil
+}
+
+// unused reports whether nothing is keeping the room alive. Caller must hold roomsMu.
+func (r *Room) unused() bool {
+	return r.members == 0 && len(r.held) == 0 && r.ID != DefaultRoomID
+}
+
+// addHold keeps the room alive while a disconnected player's seat is held
+func (r *Room) addHold(token string) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	r.held[token] = true
+}
+
+// trackToken records a new seat's session token, or forgets a freed one,
+// so reserve knows who's coming back to a seat
+func (r *Room) trackToken(token string, seated bool) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	if seated {
+		r.tokens[token] = true
+	} else {
+		delete(r.tokens, token)
+	}
+}
+
+// releaseHold drops a held seat's claim on the room. If that leaves the room
+// unused it's removed from the registry and its goroutine winds down.
+// Only called from the room's goroutine.
+func (r *Room) releaseHold(token string) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	delete(r.held, token)
+	if r.unused() && rooms[r.ID] == r {
+		delete(rooms, r.ID)
+		r.closing = true
+	}
+}
+
+// openDefaultRoom makes sure the default room exists so the lobby is never empty
+func openDefaultRoom() {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	if _, ok := rooms[DefaultRoomID]; !ok {
+		registerRoom(newRoom(DefaultRoomID, defaultRoomOptions()))
+	}
+}
+
+// openRoom finds (or creates) a room and reserves a place in it for a client.
+// The caller must follow up by sending an ActionJoin to room.actions.
+func openRoom(id, code, token string) (*Room, error) {
+	id = normalizeRoomID(id)
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	room, ok := rooms[id]
+	if !ok {
+		room = registerRoom(newRoom(id, defaultRoomOptions()))
+	}
+	if err := room.reserve(code, token); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// enterRoom reserves a place in an existing room
+func enterRoom(id, code, token string) (*Room, error) {
+	id = normalizeRoomID(id)
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	room, ok := rooms[id]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if err := room.reserve(code, token); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// RoomOptions are the settings a room is created with
+type RoomOptions struct {
+	Private     bool           // Invite-only
+	Password    string         // Invite code for a private room (generated if empty)
+	TimeControl TimeControl    // How long players get to move
+	Rules       gridwars.Rules // What games are played by (zero = classic)
+}
+
+// defaultRoomOptions are used for rooms nobody configured (the default room
+// and rooms opened straight from a ?room= link)
+func defaultRoomOptions() RoomOptions {
+	return RoomOptions{TimeControl: defaultTimeControl, Rules: defaultRules}
+}
+
+// createRoom makes a brand new room and reserves a place in it for its creator.
+// An empty ID gets a random one. Private rooms use opts.Password as their
+// invite code, or get an unguessable one if it's empty.
+func createRoom(id string, opts RoomOptions) (*Room, error) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	if strings.TrimSpace(id) == "" {
+		id = randomID(RoomIDBytes)
+		for rooms[id] != nil {
+			id = randomID(RoomIDBytes)
+		}
+	}
+	id = normalizeRoomID(id)
+
+	if _, ok := rooms[id]; ok {
+		return nil, ErrRoomExists
+	}
+	room := registerRoom(newRoom(id, opts))
+	room.members++
+	return room, nil
+}
+
+// exitRoom tells a room the client left (ActionLeave) or dropped
+// (ActionDisconnect) and shuts the room down once nothing is keeping it alive.
+// The default room is kept alive so there's always somewhere to land.
+func exitRoom(room *Room, client *Client, how ActionType) {
+	done := make(chan struct{})
+	room.actions <- Action{Type: how, Client: client, Done: done}
+	<-done
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	room.members--
+	if !room.unused() {
+		return
+	}
+	delete(rooms, room.ID)
+	close(room.actions)
+}
+
+// listRooms returns a snapshot of every live room, sorted by ID
+func listRooms() []RoomInfo {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	list := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		list = append(list, room.Info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// RoomIDBytes is how many random bytes go into a generated room ID
+const RoomIDBytes = 4
+
+// randomID returns n random bytes as a hex string
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestNormalizeRoomID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", DefaultRoomID},
+		{"   ", DefaultRoomID},
+		{"finals", "finals"},
+		{"  finals  ", "finals"},
+		{strings.Repeat("a", MaxRoomIDLength+5), strings.Repeat("a", MaxRoomIDLength)},
+	}
+
+	for _, test := range tests {
+		result := normalizeRoomID(test.input)
+		if result != test.expected {
+			t.Errorf("normalizeRoomID(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestRoomsHaveIndependentGames(t *testing.T) {
+	a := newRoom("a", RoomOptions{})
+	b := newRoom("b", RoomOptions{})
+
+	a.game.Unit("X1").HP = 1
+	a.game.Turn = "O"
+
+	if b.game.Unit("X1").HP != gridwars.Classic.MaxHP {
+		t.Errorf("room b unit affected by room a: got %d HP", b.game.Unit("X1").HP)
+	}
+	if b.game.Turn != "X" {
+		t.Errorf("room b turn affected by room a: got %s", b.game.Turn)
+	}
+}
+
+func TestCreateRoom_RejectsDuplicateID(t *testing.T) {
+	if _, err := createRoom("dup-test", RoomOptions{}); err != nil {
+		t.Fatalf("unexpected error creating room: %v", err)
+	}
+	if _, err := createRoom("dup-test", RoomOptions{}); err != ErrRoomExists {
+		t.Errorf("expected ErrRoomExists, got %v", err)
+	}
+}
+
+func TestEnterRoom_NotFoundAndFull(t *testing.T) {
+	if _, err := enterRoom("no-such-room", "", ""); err != ErrRoomNotFound {
+		t.Errorf("expected ErrRoomNotFound, got %v", err)
+	}
+
+	if _, err := createRoom("full-test", RoomOptions{}); err != nil {
+		t.Fatalf("unexpected error creating room: %v", err)
+	}
+	for i := 1; i < MaxClients; i++ {
+		if _, err := enterRoom("full-test", "", ""); err != nil {
+			t.Fatalf("enter %d failed: %v", i, err)
+		}
+	}
+	if _, err := enterRoom("full-test", "", ""); err != ErrRoomFull {
+		t.Errorf("expected ErrRoomFull, got %v", err)
+	}
+}
+
+func TestListRooms_IncludesCreatedRoom(t *testing.T) {
+	if _, err := createRoom("listed-test", RoomOptions{}); err != nil {
+		t.Fatalf("unexpected error creating room: %v", err)
+	}
+
+	for _, info := range listRooms() {
+		if info.ID == "listed-test" {
+			if info.Turn != "X" {
+				t.Errorf("expected new room turn X, got %s", info.Turn)
+			}
+			return
+		}
+	}
+	t.Error("created room missing from listing")
+}
+
+func TestPrivateRoom_RequiresInviteCode(t *testing.T) {
+	room, err := createRoom("private-test", RoomOptions{Private: true})
+	if err != nil {
+		t.Fatalf("unexpected error creating room: %v", err)
+	}
+	if len(room.inviteCode) != InviteCodeBytes*2 {
+		t.Errorf("expected generated invite code, got %q", room.inviteCode)
+	}
+	if !room.Info().Private {
+		t.Error("expected room to be listed as private")
+	}
+
+	if _, err := enterRoom("private-test", "", ""); err != ErrInviteCode {
+		t.Errorf("expected ErrInviteCode without a code, got %v", err)
+	}
+	if _, err := enterRoom("private-test", "wrong", ""); err != ErrInviteCode {
+		t.Errorf("expected ErrInviteCode with wrong code, got %v", err)
+	}
+	if _, err := enterRoom("private-test", room.inviteCode, ""); err != nil {
+		t.Errorf("expected join with invite code to succeed, got %v", err)
+	}
+}
+
+func TestPrivateRoom_PasswordBecomesCode(t *testing.T) {
+	room, err := createRoom("password-test", RoomOptions{Private: true, Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("unexpected error creating room: %v", err)
+	}
+	if _, err := openRoom("password-test", "hunter2", ""); err != nil {
+		t.Errorf("expected join with password to succeed, got %v", err)
+	}
+	if room.inviteCode != "hunter2" {
+		t.Errorf("expected password as invite code, got %q", room.inviteCode)
+	}
+}
+
+// syncRoom waits until a room has handled everything sent to it so far
+func syncRoom(room *Room, client *Client) {
+	done := make(chan struct{})
+	room.actions <- Action{Client: client, Done: done}
+	<-done
+}
+
+func TestLobby_MovesClientBetweenRooms(t *testing.T) {
+	a, b := &Client{}, &Client{}
+	handleLobbyMessage(a, ClientMessage{Type: string(ActionCreateRoom), Room: "lobby-move-a"})
+	handleLobbyMessage(b, ClientMessage{Type: string(ActionCreateRoom), Room: "lobby-move-b"})
+	roomA, roomB := a.room, b.room
+	if roomA == nil || roomB == nil || roomA == roomB {
+		t.Fatalf("expected two new rooms, got %v and %v", roomA, roomB)
+	}
+	defer exitRoom(roomB, b, ActionLeave)
+	syncRoom(roomA, a)
+	if a.Role != "X" || !roomA.clients[a] {
+		t.Fatalf("expected the creator seated as X, got %q", a.Role)
+	}
+
+	handleLobbyMessage(a, ClientMessage{Type: string(ActionJoinRoom), Room: "lobby-move-b"})
+	syncRoom(roomB, b)
+	if a.room != roomB || !roomB.clients[a] || roomA.clients[a] {
+		t.Fatal("expected the client moved from the first room to the second")
+	}
+	if a.Role != "O" || roomB.seatFor("O").Client != a {
+		t.Errorf("expected the client seated as O in the second room, got %q", a.Role)
+	}
+	roomsMu.Lock()
+	_, open := rooms["lobby-move-a"]
+	roomsMu.Unlock()
+	if open {
+		t.Error("expected the empty first room closed")
+	}
+
+	handleLobbyMessage(a, ClientMessage{Type: string(ActionLeaveRoom)})
+	syncRoom(roomB, b)
+	if a.room != nil || roomB.clients[a] || roomB.seatFor("O") != nil {
+		t.Error("expected the client back in the lobby with their seat freed")
+	}
+}
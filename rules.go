@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// ErrRules is returned for a rule preset that doesn't exist
+var ErrRules = fmt.Errorf("Unknown rules (use %s)", presetNames())
+
+// presetNames lists every rule preset for ErrRules, like `"classic" or "blitz"`
+func presetNames() string {
+	names := make([]string, len(gridwars.Presets))
+	for i, rules := range gridwars.Presets {
+		names[i] = strconv.Quote(rules.Name)
+	}
+	last := len(names) - 1
+	if last < 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:last], ", ") + " or " + names[last]
+}
+
+// parseRules looks up a rule preset by name
+func parseRules(name string) (gridwars.Rules, error) {
+	rules, ok := gridwars.Preset(name)
+	if !ok {
+		return gridwars.Rules{}, ErrRules
+	}
+	return rules, nil
+}
+
+// apply runs an action through the rules, then tells everyone what happened.
+// Returns the rules' error if the action isn't allowed.
+func (r *Room) apply(action gridwars.Action) error {
+	// Charge the player on turn for their time up to now before anything changes
+	if clock := r.game.Clock; clock != nil {
+		clock.tick(time.Now(), r.game.Turn, r.clockRunning())
+	}
+
+	state, events, err := gridwars.Apply(r.game.State, action, r.rng)
+	if err != nil {
+		return err
+	}
+	r.game.State = state
+
+	// Settle clocks and draw offers first so every message below has them right
+	for _, e := range events {
+		if e.Type == gridwars.EventTurnEnded {
+			r.turnEnded(e.Mark)
+		}
+	}
+
+	// Only the game as the whole action left it is known, so only the last
+	// replay step it logs can show the board
+	last := -1
+	for i, e := range events {
+		if changesBoard(e.Type) {
+			last = i
+		}
+	}
+	for i, e := range events {
+		r.handleEvent(e, i == last)
+	}
+	return nil
+}
+
+// changesBoard reports whether an event's replay step shows the board
+func changesBoard(t gridwars.EventType) bool {
+	switch t {
+	case gridwars.EventMoved, gridwars.EventPickedUp, gridwars.EventPowerUpSpawned,
+		gridwars.EventCombatResolved, gridwars.EventPicked:
+		return true
+	}
+	return false
+}
+
+// recordStep logs a step that changed the board, with the state only if
+// it's the action's last (see apply)
+func (r *Room) recordStep(e Event, last bool) {
+	if last {
+		r.recordState(e)
+	} else {
+		r.record(e)
+	}
+}
+
+// handleEvent logs one thing the rules say happened and sends clients
+// whatever they need to show it. last is set for the last event that
+// changed the board.
+func (r *Room) handleEvent(e gridwars.Event, last bool) {
+	switch e.Type {
+	case gridwars.EventMoved:
+		r.recordStep(Event{Type: EventMove, Mark: e.Mark, Unit: e.Unit, X: e.X, Y: e.Y}, last)
+
+	case gridwars.EventPickedUp:
+		r.recordStep(Event{Type: EventPickup, Mark: e.Mark, Unit: e.Unit, X: e.X, Y: e.Y, PowerUp: e.PowerUp}, last)
+		rules := r.game.Rules
+		message := fmt.Sprintf("%s collected HP boost! (+%d HP)", e.Mark, rules.HPBoost)
+		if e.PowerUp.Type == "attack" {
+			message = fmt.Sprintf("%s collected Attack boost! (Next attack deals %d damage)", e.Mark, rules.BoostDamage)
+		}
+		r.broadcastToAll(ServerMessage{Type: "chat", From: "system", Message: message})
+
+	case gridwars.EventPowerUpSpawned:
+		r.recordStep(Event{Type: EventSpawn, X: e.X, Y: e.Y, PowerUp: e.PowerUp}, last)
+
+	case gridwars.EventAttacked:
+		r.record(Event{Type: EventAttack, Mark: e.Mark, Unit: e.Unit, X: e.X, Y: e.Y})
+
+	case gridwars.EventDiceRolled:
+		r.startCombat(e.Combat)
+
+	case gridwars.EventRolled:
+		if e.Mark == e.Combat.AttackerMark {
+			// Defender gets a fresh window once the attacker's die is in
+			r.pendingCombat.startRollTimer(time.Now())
+		}
+		r.record(Event{Type: EventRoll, Mark: e.Mark, Roll: e.Roll})
+
+		// Includes attacker's roll once it's in (so defender knows what to beat)
+		r.broadcastToAll(ServerMessage{Type: "combat_rolled", Combat: r.combatMessage(e.Combat)})
+
+	case gridwars.EventCombatResolved:
+		r.endCombat(e.Combat, last)
+
+	case gridwars.EventPicked:
+		r.recordStep(Event{Type: EventPick, Mark: e.Mark, Text: e.Class, Random: e.Random}, last)
+		if r.game.Draft != nil {
+			r.startPickTimer(time.Now())
+			r.broadcastToAll(r.draftMessage())
+		}
+
+	case gridwars.EventDraftEnded:
+		r.pickDeadline = time.Time{}
+		r.broadcastToAll(ServerMessage{Type: "chat", From: "system", Message: "Squads picked - " + r.game.Turn + " to move"})
+		r.broadcastToAll(ServerMessage{Type: "state", Game: r.game})
+	}
+}
+
+// startCombat commits to the secretly rolled dice and starts the roll timer
+func (r *Room) startCombat(combat *gridwars.Combat) {
+	r.pendingCombat = &PendingCombat{}
+	r.pendingCombat.commit(combat)
+	r.pendingCombat.startRollTimer(time.Now())
+
+	dice := newCombatResult(combat, true)
+	dice.Commitment, dice.Salt = r.pendingCombat.Commitment, r.pendingCombat.Salt
+	r.record(Event{Type: EventDice, Hidden: true, Combat: dice})
+
+	// Broadcast combat start (without revealing rolls)
+	r.broadcastToAll(ServerMessage{Type: "combat_start", Combat: r.combatMessage(combat)})
+}
+
+// endCombat announces a resolved combat along with the new state
+func (r *Room) endCombat(combat *gridwars.Combat, last bool) {
+	result := newCombatResult(combat, true)
+	if combat.Boosted {
+		r.recordStep(Event{Type: EventCombat, Combat: result}, last)
+		r.broadcastToAll(ServerMessage{Type: "combat_boosted", Game: r.game, Combat: result})
+		return
+	}
+
+	result.Commitment, result.Salt = r.pendingCombat.Commitment, r.pendingCombat.Salt
+	r.pendingCombat = nil
+	r.recordStep(Event{Type: EventCombat, Combat: result}, last)
+
+	// Broadcast final combat result (revealing the salt) and new state
+	r.broadcastToAll(ServerMessage{Type: "combat", Game: r.game, Combat: result})
+}
+
+// combatMessage describes a pending combat without giving away unrevealed dice
+func (r *Room) combatMessage(combat *gridwars.Combat) *CombatResult {
+	msg := newCombatResult(combat, false)
+	if r.pendingCombat != nil {
+		msg.RollDeadline = r.pendingCombat.deadlineMillis()
+		msg.Commitment = r.pendingCombat.Commitment
+	}
+	return msg
+}
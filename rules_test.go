@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, preset := range gridwars.Presets {
+		if rules, err := parseRules(preset.Name); err != nil || rules != preset {
+			t.Errorf("parseRules(%q) = %+v, %v", preset.Name, rules, err)
+		}
+		if !strings.Contains(ErrRules.Error(), `"`+preset.Name+`"`) {
+			t.Errorf("expected ErrRules to list %q, got %q", preset.Name, ErrRules)
+		}
+	}
+	if _, err := parseRules("chess"); err != ErrRules {
+		t.Errorf("expected ErrRules for an unknown preset, got %v", err)
+	}
+}
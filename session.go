@@ -0,0 +1,167 @@
+package main
+
+import "time"
+
+// SessionTokenBytes is how many random bytes go into a seat's session token
+const SessionTokenBytes = 16
+
+// Seat remembers who holds a player slot so it survives a dropped connection
+type Seat struct {
+	Token     string    // Handed to the player in "assigned", presented to resume
+	Mark      string    // "X" or "O"
+	Name      string    // Display name to restore on resume
+	Client    *Client   // nil while the player is disconnected
+	HeldUntil time.Time // When a disconnected seat is given up
+}
+
+// seatFor returns the seat for a mark, or nil if nobody holds it
+func (r *Room) seatFor(mark string) *Seat {
+	return r.seats[mark]
+}
+
+// takeSeat gives a client a fresh seat (and session token) for a mark
+func (r *Room) takeSeat(client *Client, mark string) *Seat {
+	seat := &Seat{
+		Token:  randomID(SessionTokenBytes),
+		Mark:   mark,
+		Name:   client.Name,
+		Client: client,
+	}
+	r.seats[mark] = seat
+	r.trackToken(seat.Token, true)
+	r.setAccount(mark, client.account)
+	return seat
+}
+
+// freeSeat gives up a seat entirely so the next joiner can take it
+func (r *Room) freeSeat(mark string) {
+	seat := r.seats[mark]
+	if seat == nil {
+		return
+	}
+	delete(r.seats, mark)
+	r.trackToken(seat.Token, false)
+	if seat.Client == nil {
+		r.releaseHold(seat.Token)
+	}
+	if seat.isBot() {
+		delete(r.clients, seat.Client) // Bots only exist to fill their seat
+	}
+
+	// Don't leave a combat waiting on dice that will never be rolled,
+	// or a rematch waiting on an answer that will never come
+	r.finishCombatFor(mark)
+	r.cancelProposal()
+	r.game.DrawOffer = ""
+
+	// Walking out of a game in progress hands it to the opponent
+	r.checkAbandoned(mark)
+
+	// Next spectator in line takes the empty seat
+	r.promoteFromQueue()
+
+	// A bot left on its own has nobody to play
+	r.dismissBots()
+}
+
+// holdSeat keeps a disconnected player's seat for seatGracePeriod, or
+// longer if nobody's left playing (see updateHolds).
+// Returns false if holding is disabled and the seat was freed instead.
+func (r *Room) holdSeat(seat *Seat) bool {
+	if seatGracePeriod <= 0 {
+		r.freeSeat(seat.Mark)
+		return false
+	}
+	now := time.Now()
+	seat.Client = nil
+	seat.HeldUntil = now.Add(seatGracePeriod)
+	r.addHold(seat.Token)
+	r.updateHolds(now)
+	return true
+}
+
+// updateHolds sets how long held seats wait for their players. While
+// someone's still connected and waiting, that's seatGracePeriod. In a saved
+// room that nobody's connected to play in, it's savedSeatGracePeriod: the
+// server may be stopped while it's idle, and the game should still be there
+// when the players come back.
+func (r *Room) updateHolds(now time.Time) {
+	idle := r.persisted
+	for _, seat := range r.seats {
+		if seat.Client != nil && !seat.isBot() {
+			idle = false
+		}
+	}
+	wait := seatGracePeriod
+	if idle {
+		wait = max(savedSeatGracePeriod, seatGracePeriod)
+	}
+	for _, seat := range r.seats {
+		if seat.Client == nil && (idle || seat.HeldUntil.After(now.Add(wait))) {
+			seat.HeldUntil = now.Add(wait)
+		}
+	}
+}
+
+// resumeSeat hands a seat back to a reconnecting client. If the seat still
+// has a connection, the player came back before the server noticed it drop,
+// so the old connection is closed and the new one takes over.
+// Returns nil if the token doesn't match a seat.
+func (r *Room) resumeSeat(client *Client, token string) *Seat {
+	if token == "" {
+		return nil
+	}
+	for _, seat := range r.seats {
+		if seat.Token != token || seat.isBot() {
+			continue
+		}
+		if stale := seat.Client; stale != nil && stale != client {
+			delete(r.clients, stale)
+			stale.Role = ""
+			if stale.Conn != nil {
+				stale.Conn.Close() // Its reader sees the error and leaves the room
+			}
+		}
+		seat.Client = client
+		seat.HeldUntil = time.Time{}
+		if client.Name == "" {
+			client.Name = seat.Name
+			client.account = r.game.Accounts[seat.Mark]
+		}
+		r.releaseHold(token)
+		r.updateHolds(time.Now()) // Anyone still away now has someone waiting on them
+		return seat
+	}
+	return nil
+}
+
+// expireSeats frees any held seats whose grace period has run out
+func (r *Room) expireSeats(now time.Time) {
+	for mark, seat := range r.seats {
+		if seat.Client != nil || now.Before(seat.HeldUntil) {
+			continue
+		}
+		r.freeSeat(mark)
+		r.broadcastToAll(ServerMessage{
+			Type:    "chat",
+			From:    "system",
+			Message: mark + " did not reconnect - seat is open",
+		})
+	}
+}
+
+// sendCombatSnapshot replays an in-progress combat to a (re)joining client
+// so their dice overlay picks up where it left off
+func (r *Room) sendCombatSnapshot(client *Client) {
+	combat := r.game.Combat
+	if combat == nil {
+		return
+	}
+
+	start := r.combatMessage(combat)
+	start.AttackerRoll, start.AttackerRolled, start.DefenderRolled = 0, false, false
+	r.send(client, ServerMessage{Type: "combat_start", Combat: start})
+	if combat.AttackerRolled {
+		r.send(client, ServerMessage{Type: "combat_rolled", Combat: r.combatMessage(combat)})
+	}
+}
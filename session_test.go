@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHoldSeat_KeepsSeatAndResumesWithToken(t *testing.T) {
+	r := newRoom("session-test", RoomOptions{})
+	player := &Client{Name: "alice"}
+	seat := r.takeSeat(player, "X")
+
+	if !r.holdSeat(seat) {
+		t.Fatal("expected seat to be held")
+	}
+	if r.seatFor("X") != seat {
+		t.Error("held seat should stay taken so nobody else takes it")
+	}
+
+	// Wrong token doesn't resume
+	if r.resumeSeat(&Client{}, "wrong") != nil {
+		t.Error("resumed with the wrong token")
+	}
+
+	returning := &Client{}
+	resumed := r.resumeSeat(returning, seat.Token)
+	if resumed == nil || resumed.Mark != "X" {
+		t.Fatalf("expected to resume seat X, got %+v", resumed)
+	}
+	if returning.Name != "alice" {
+		t.Errorf("expected name restored to alice, got %q", returning.Name)
+	}
+	if len(r.held) != 0 {
+		t.Errorf("expected hold released on resume, still holding %d", len(r.held))
+	}
+}
+
+func TestExpireSeats_FreesSeatAfterGracePeriod(t *testing.T) {
+	r := newRoom("expire-test", RoomOptions{})
+	seat := r.takeSeat(&Client{}, "O")
+	r.holdSeat(seat)
+
+	// Still inside the grace period
+	r.expireSeats(time.Now())
+	if r.seatFor("O") == nil {
+		t.Fatal("seat expired too early")
+	}
+
+	r.expireSeats(seat.HeldUntil.Add(time.Second))
+	if r.seatFor("O") != nil {
+		t.Error("expected seat O to be freed after grace period")
+	}
+	if r.resumeSeat(&Client{}, seat.Token) != nil {
+		t.Error("expired token should not resume")
+	}
+}
+
+func TestHoldSeat_DisabledFreesSeat(t *testing.T) {
+	saved := seatGracePeriod
+	seatGracePeriod = 0
+	defer func() { seatGracePeriod = saved }()
+
+	r := newRoom("no-hold-test", RoomOptions{})
+	seat := r.takeSeat(&Client{}, "X")
+
+	if r.holdSeat(seat) {
+		t.Error("expected holding to be disabled")
+	}
+	if r.seatFor("X") != nil {
+		t.Error("expected seat to be freed")
+	}
+}
+
+func TestResumeSeat_TakesOverStillConnectedSeat(t *testing.T) {
+	r := newRoom("takeover-test", RoomOptions{Private: true})
+	old, returning := &Client{Name: "alice"}, &Client{}
+	r.handleJoin(old, "")
+	token := r.seatFor("X").Token
+
+	// The server hasn't noticed the old connection drop, but the token still gets in
+	if err := r.reserve("", token); err != nil {
+		t.Fatalf("expected the token to get past the invite code, got %v", err)
+	}
+	r.handleJoin(returning, token)
+	if returning.Role != "X" || r.seatFor("X").Client != returning || r.clients[old] {
+		t.Fatalf("expected the new connection to take over seat X, got role %q", returning.Role)
+	}
+
+	// The old connection's disconnect finally arrives - the seat stays put
+	r.handleLeave(old, true)
+	if seat := r.seatFor("X"); seat.Client != returning || len(r.held) != 0 {
+		t.Errorf("expected the seat to stay with the new connection, got %+v", seat)
+	}
+}
+
+func TestKeepAlive_NoticesDeadConnection(t *testing.T) {
+	savedWait, savedPeriod := pongWait, pingPeriod
+	pongWait, pingPeriod = 200*time.Millisecond, 50*time.Millisecond
+	defer func() { pongWait, pingPeriod = savedWait, savedPeriod }()
+
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=keepalive-test"
+
+	// X never reads, so never answers a ping - like a socket that died quietly
+	silent, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	live, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	go func() {
+		for {
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	away := func(mark string) bool {
+		for _, room := range listRooms() {
+			for _, p := range room.Players {
+				if room.ID == "keepalive-test" && p.Mark == mark {
+					return p.Away
+				}
+			}
+		}
+		return false
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !away("X") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !away("X") {
+		t.Fatal("expected X's seat held once it stopped answering pings")
+	}
+	time.Sleep(2 * pongWait)
+	if away("O") {
+		t.Error("expected O to stay connected while answering pings")
+	}
+}
+
+func TestHoldSeat_SavedRoomHoldsLongerOnceEveryoneLeaves(t *testing.T) {
+	r := newRoom("saved-hold-test", RoomOptions{})
+	r.persisted = true
+	seatX, seatO := r.takeSeat(&Client{}, "X"), r.takeSeat(&Client{}, "O")
+	soon := func(seat *Seat) bool { return !seat.HeldUntil.After(time.Now().Add(seatGracePeriod)) }
+
+	// O is still there waiting on X
+	r.holdSeat(seatX)
+	if !soon(seatX) {
+		t.Fatalf("expected X held for the usual grace period, until %v", seatX.HeldUntil)
+	}
+
+	// Nobody's left - the room may be stopped, so the game waits
+	r.holdSeat(seatO)
+	if soon(seatX) || soon(seatO) {
+		t.Fatal("expected both seats held for much longer once nobody's connected")
+	}
+	r.expireSeats(time.Now().Add(2 * seatGracePeriod))
+	if r.seatFor("X") == nil || r.seatFor("O") == nil || r.game.Winner != "" {
+		t.Fatal("expected the game kept for the players to come back to")
+	}
+
+	// O's back and waiting on X again
+	r.resumeSeat(&Client{}, seatO.Token)
+	if !soon(seatX) {
+		t.Errorf("expected X's hold cut back once O returned, until %v", seatX.HeldUntil)
+	}
+}
@@ -1,23 +1,71 @@
 let myMark = null;
+let myRoom = null; // Room ID we're in, null while in the lobby
 let gameState = null;
 let ws = null;
-let selectedCell = null; // {x, y} of selected unit
+let selectedUnit = null; // ID of the selected unit
 let pendingGameState = null; // Game state to apply after combat animation
+let rollDeadline = 0; // Unix ms when the server rolls for the idle combatant
+let pickDeadline = 0; // Unix ms when the server picks for whoever's drafting
+let replay = null; // Replay being viewed {gameId, events, step}
+let queuePosition = 0; // Our place in the "next up" queue, 0 if not queued
+let signedInAs = null; // Account name, null for guests
+let searching = false; // Waiting in the matchmaking queue
 let combatState = null; // Tracks current combat {attackerMark, defenderMark, attackerRolled, defenderRolled, myRoll}
 
-const BOARD_SIZE = 9;
 const DICE_FACES = ['⚀', '⚁', '⚂', '⚃', '⚄', '⚅']; // 1-6
 
+const RECONNECT_DELAY_MS = 2000;
+
+// Session token for our seat, kept across reconnects (and page refreshes)
+function loadSession() {
+    return JSON.parse(sessionStorage.getItem('session') || 'null');
+}
+
+// Account we sign in to on connecting ({name, key}), kept across visits
+function loadAccount() {
+    return JSON.parse(localStorage.getItem('account') || 'null');
+}
+
+function saveAccount(name, key) {
+    localStorage.setItem('account', JSON.stringify({ name, key }));
+}
+
+function saveSession(room, token) {
+    if (token) {
+        sessionStorage.setItem('session', JSON.stringify({ room, token }));
+    } else {
+        sessionStorage.removeItem('session');
+    }
+}
+
 function connect() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
+    // Resume our seat if we have a session, otherwise join the room from the
+    // page URL (?room=<id>&code=<invite>), or start in the lobby
+    const params = new URLSearchParams(window.location.search);
+    const session = loadSession();
+    const room = session ? session.room : params.get('room');
+    let url = `${protocol}//${window.location.host}/ws`;
+    if (room) {
+        url += `?room=${encodeURIComponent(room)}&code=${encodeURIComponent(params.get('code') || '')}`;
+        if (session) {
+            url += `&token=${encodeURIComponent(session.token)}`;
+        }
+    }
+    ws = new WebSocket(url);
 
     ws.onopen = function() {
         console.log('Connected');
+        const account = loadAccount();
+        if (account) {
+            ws.send(JSON.stringify({ type: 'signIn', name: account.name, key: account.key }));
+        }
     };
 
     ws.onclose = function() {
-        document.getElementById('status').textContent = 'Disconnected - refresh to reconnect';
+        document.getElementById('status').textContent = 'Disconnected - reconnecting...';
+        setSearching(false, ''); // The server forgets us
+        setTimeout(connect, RECONNECT_DELAY_MS);
     };
 
     ws.onmessage = function(event) {
@@ -28,19 +76,75 @@ function connect() {
 }
 
 function handleMessage(msg) {
+    if (msg.game) {
+        noteClock(msg.game);
+        // Keep the server's move hints with the game they were worked out for
+        msg.game.hints = msg.hints || null;
+    }
+
     switch (msg.type) {
         case 'assigned':
+            // Sent to everyone when a rematch starts, which settles any proposal
+            hideProposal();
             myMark = msg.mark;
+            saveSession(msg.room, msg.token);
+            if (msg.room && msg.room !== myRoom) {
+                myRoom = msg.room;
+                document.getElementById('chat-messages').innerHTML = '';
+            }
             document.getElementById('player-info').textContent = `You are: ${myMark}`;
+            showGameArea();
+            renderQueueButton();
+            renderBotControls();
+            break;
+
+        case 'replay':
+            addReplayEvent(msg.gameId, msg.event);
+            break;
+
+        case 'queue':
+            renderQueue(msg.queue || [], msg.position || 0);
+            break;
+
+        case 'rooms':
+            renderRoomList(msg.rooms || []);
+            break;
+
+        case 'invite':
+            showInvite(msg.room, msg.code);
+            break;
+
+        case 'leftRoom':
+            saveSession(null, null);
+            myMark = null;
+            myRoom = null;
+            gameState = null;
+            pickDeadline = 0;
+            renderDraft();
+            renderQueue([], 0);
+            document.getElementById('player-info').textContent = '';
+            document.getElementById('invite-info').classList.add('hidden');
+            showLobby();
             break;
 
         case 'state':
             gameState = msg.game;
-            selectedCell = null;
+            selectedUnit = null;
             renderBoard();
+            renderDraft();
             updateStatus();
             break;
 
+        case 'draft_state':
+            // Someone picked - the board stays empty until both squads are full
+            if (gameState) {
+                gameState.draft = msg.draft;
+                pickDeadline = msg.draft.deadline || 0;
+                renderDraft();
+                updateStatus();
+            }
+            break;
+
         case 'combat_start':
             // Combat initiated - show overlay with clickable dice
             showCombatStart(msg.combat);
@@ -53,7 +157,9 @@ function handleMessage(msg) {
 
         case 'combat':
             // Both rolled - show final result
+            rollDeadline = 0;
             pendingGameState = msg.game;
+            checkCombatDice(msg.combat);
             showCombatResult(msg.combat);
             break;
 
@@ -65,11 +171,51 @@ function handleMessage(msg) {
 
         case 'error':
             console.error('Error:', msg.error);
+            if (!myRoom) {
+                alert(msg.error);
+            }
+            break;
+
+        case 'searching':
+            setSearching(true, msg.message);
+            break;
+
+        case 'matchCancelled':
+        case 'matched':
+            setSearching(false, '');
+            break;
+
+        case 'account':
+            // The key only comes back when the name was just registered
+            if (msg.account.key) {
+                saveAccount(msg.account.name, msg.account.key);
+            }
+            signedInAs = msg.account.name;
+            document.getElementById('name-input').value = msg.account.name;
+            document.getElementById('account-info').textContent = `Signed in as ${msg.account.name} (${msg.account.rating})`;
             break;
 
         case 'chat':
             addChatMessage(msg.from, msg.name, msg.message);
             break;
+
+        case 'draw_offered':
+            showDrawOffer(msg.from);
+            break;
+
+        case 'draw_declined':
+            hideDrawOffer();
+            addChatMessage('system', '', `${msg.from} declined the draw`);
+            break;
+
+        case 'rematch_proposed':
+            showProposal(msg.proposal);
+            break;
+
+        case 'rematch_declined':
+            hideProposal();
+            addChatMessage('system', '', `${msg.proposal.kind} proposal from ${msg.proposal.from} was declined`);
+            break;
     }
 }
 
@@ -78,6 +224,12 @@ function getDiceFace(value) {
     return DICE_FACES[value - 1] || '⚀';
 }
 
+// A roll with its unit's class bonus, e.g. "4+1"
+function rollText(roll, bonus) {
+    if (!bonus) return `${roll}`;
+    return bonus > 0 ? `${roll}+${bonus}` : `${roll}${bonus}`;
+}
+
 // Show combat overlay when combat starts - dice are clickable
 function showCombatStart(combat) {
     const overlay = document.getElementById('combat-overlay');
@@ -90,6 +242,8 @@ function showCombatStart(combat) {
     const attackerDamage = document.getElementById('attacker-damage');
     const defenderDamage = document.getElementById('defender-damage');
 
+    rollDeadline = combat.rollDeadline || 0;
+
     // Store combat state
     combatState = {
         attackerMark: combat.attackerMark,
@@ -97,8 +251,10 @@ function showCombatStart(combat) {
         attackerRolled: false,
         defenderRolled: false,
         attackerInterval: null,
-        defenderInterval: null
+        defenderInterval: null,
+        commitment: combat.commitment // Hash of the pre-rolled dice, checked against the result
     };
+    document.getElementById('combat-verify').textContent = combat.commitment ? `Dice committed: ${combat.commitment.slice(0, 12)}...` : '';
 
     // Set up labels - show "YOU" if it's the player
     const isAttacker = myMark === combat.attackerMark;
@@ -182,6 +338,8 @@ function handleDiceClick(side) {
 function handleCombatRolled(combat) {
     if (!combatState) return;
 
+    rollDeadline = combat.defenderRolled ? 0 : (combat.rollDeadline || 0);
+
     const attackerDice = document.getElementById('attacker-dice');
     const defenderDice = document.getElementById('defender-dice');
     const attackerResult = document.getElementById('attacker-result');
@@ -210,13 +368,13 @@ function handleCombatRolled(combat) {
             }
             attackerDice.classList.remove('rolling');
             attackerDice.textContent = getDiceFace(combat.attackerRoll);
-            attackerResult.textContent = `Rolled ${combat.attackerRoll}!`;
+            attackerResult.textContent = `Rolled ${rollText(combat.attackerRoll, combat.attackerBonus)}!`;
 
             // Now defender can roll!
             defenderDice.classList.remove('waiting');
             if (isDefender) {
                 defenderDice.classList.add('clickable');
-                defenderResult.textContent = `Beat ${combat.attackerRoll} to win! Click to roll!`;
+                defenderResult.textContent = `Beat ${rollText(combat.attackerRoll, combat.attackerBonus)} to win! Click to roll!`;
             } else {
                 defenderDice.classList.add('waiting');
                 defenderResult.textContent = 'Waiting...';
@@ -238,6 +396,28 @@ function handleCombatRolled(combat) {
     }
 }
 
+// Checks revealed dice against the commitment published at combat start.
+// Mirrors gridwars.VerifyDice: hex(sha256("<attackerRoll>:<defenderRoll>:<salt>"))
+async function verifyDice(commitment, attackerRoll, defenderRoll, salt) {
+    const data = new TextEncoder().encode(`${attackerRoll}:${defenderRoll}:${salt}`);
+    const digest = await crypto.subtle.digest('SHA-256', data);
+    const hex = Array.from(new Uint8Array(digest), b => b.toString(16).padStart(2, '0')).join('');
+    return hex === commitment;
+}
+
+function checkCombatDice(combat) {
+    const verifyEl = document.getElementById('combat-verify');
+    const commitment = combatState && combatState.commitment;
+    if (!commitment || !window.crypto || !crypto.subtle) return; // subtle needs https (or localhost)
+
+    verifyDice(commitment, combat.attackerRoll, combat.defenderRoll, combat.salt).then(ok => {
+        verifyEl.textContent = ok ? 'Dice verified against the commitment ✓' : 'Dice do NOT match the commitment!';
+        if (!ok) {
+            addChatMessage('system', '', `Warning: combat dice ${combat.attackerRoll}-${combat.defenderRoll} did not match the server's commitment`);
+        }
+    });
+}
+
 // Show final combat result after both rolled
 function showCombatResult(combat) {
     const attackerDice = document.getElementById('attacker-dice');
@@ -273,15 +453,15 @@ function showCombatResult(combat) {
         defenderDice.textContent = getDiceFace(combat.defenderRoll);
 
         // Show roll values
-        attackerResult.textContent = `Rolled ${combat.attackerRoll}`;
-        defenderResult.textContent = `Rolled ${combat.defenderRoll}`;
+        attackerResult.textContent = `Rolled ${rollText(combat.attackerRoll, combat.attackerBonus)}`;
+        defenderResult.textContent = `Rolled ${rollText(combat.defenderRoll, combat.defenderBonus)}`;
 
         // After a beat, show winner and damage
         setTimeout(() => {
             if (combat.winner === 'attacker') {
-                attackerResult.textContent = `Rolled ${combat.attackerRoll} - WINS!`;
+                attackerResult.textContent = `Rolled ${rollText(combat.attackerRoll, combat.attackerBonus)} - WINS!`;
                 attackerResult.className = 'combat-result hit';
-                defenderResult.textContent = `Rolled ${combat.defenderRoll} - loses`;
+                defenderResult.textContent = `Rolled ${rollText(combat.defenderRoll, combat.defenderBonus)} - loses`;
                 defenderResult.className = 'combat-result miss';
 
                 setTimeout(() => {
@@ -289,9 +469,9 @@ function showCombatResult(combat) {
                     defenderDamage.className = 'damage-number show';
                 }, 300);
             } else {
-                defenderResult.textContent = `Rolled ${combat.defenderRoll} - WINS!`;
+                defenderResult.textContent = `Rolled ${rollText(combat.defenderRoll, combat.defenderBonus)} - WINS!`;
                 defenderResult.className = 'combat-result hit';
-                attackerResult.textContent = `Rolled ${combat.attackerRoll} - loses`;
+                attackerResult.textContent = `Rolled ${rollText(combat.attackerRoll, combat.attackerBonus)} - loses`;
                 attackerResult.className = 'combat-result miss';
 
                 setTimeout(() => {
@@ -308,9 +488,23 @@ function showCombatResult(combat) {
     }, 1000); // Let defender's dice spin for 1 second
 }
 
+// Roll deadline countdown - the server rolls for whoever is idle when it hits zero
+function renderRollTimer() {
+    const timerEl = document.getElementById('combat-timer');
+    if (!combatState || !rollDeadline) {
+        timerEl.textContent = '';
+        return;
+    }
+    const seconds = Math.max(0, Math.ceil((rollDeadline - Date.now()) / 1000));
+    timerEl.textContent = `Auto-roll in ${seconds}s`;
+}
+
+setInterval(renderRollTimer, 250);
+
 function hideCombatOverlay() {
     const overlay = document.getElementById('combat-overlay');
     overlay.classList.remove('active');
+    rollDeadline = 0;
 
     // Clear combat state
     combatState = null;
@@ -319,7 +513,7 @@ function hideCombatOverlay() {
     if (pendingGameState) {
         gameState = pendingGameState;
         pendingGameState = null;
-        selectedCell = null;
+        selectedUnit = null;
         renderBoard();
         updateStatus();
     }
@@ -399,6 +593,95 @@ function showBoostedAttack(combat) {
     }, 3000);
 }
 
+// Lobby functions
+function showLobby() {
+    loadReplayList();
+    document.getElementById('lobby').classList.remove('hidden');
+    document.getElementById('game-area').classList.add('hidden');
+}
+
+function showGameArea() {
+    document.getElementById('lobby').classList.add('hidden');
+    document.getElementById('game-area').classList.remove('hidden');
+    document.getElementById('room-name').textContent = myRoom;
+}
+
+function renderRoomList(rooms) {
+    const listEl = document.getElementById('room-list');
+    listEl.innerHTML = '';
+
+    if (rooms.length === 0) {
+        listEl.textContent = 'No rooms yet - create one!';
+    }
+
+    for (const room of rooms) {
+        const item = document.createElement('div');
+        item.className = 'room-item';
+
+        const players = room.players.map(p => (p.name ? `${p.name} (${p.mark})` : p.mark) + (p.rating ? ` ${p.rating}` : '') + (p.away ? ' [away]' : '')).join(' vs ') || 'empty';
+        let status = `${room.players.length}/2 players, ${room.spectators} watching`;
+        if (room.queued) {
+            status += `, ${room.queued} queued`;
+        }
+        if (room.private) {
+            status = `private - ${status}`;
+        }
+        if (room.clock && room.clock !== 'none') {
+            status += `, ${room.clock}`;
+        }
+        if (room.rules && room.rules !== 'classic') {
+            status += `, ${room.rules} rules`;
+        }
+        if (room.winner) {
+            status += ` - ${room.winner} won`;
+        } else if (room.players.length === 2) {
+            status += ` - ${room.turn} to move`;
+        }
+
+        const label = document.createElement('span');
+        label.innerHTML = `<strong>${escapeHtml(room.id)}</strong>: ${escapeHtml(players)} <em>(${escapeHtml(status)})</em>`;
+        item.appendChild(label);
+
+        const joinBtn = document.createElement('button');
+        joinBtn.textContent = 'Join';
+        joinBtn.onclick = () => joinRoom(room);
+        item.appendChild(joinBtn);
+
+        listEl.appendChild(item);
+    }
+
+    if (!myRoom) {
+        showLobby();
+    }
+}
+
+function joinRoom(room) {
+    let code = '';
+    if (room.private) {
+        code = prompt('This room is private. Enter the invite code:');
+        if (code === null) return;
+    }
+    ws.send(JSON.stringify({ type: 'joinRoom', room: room.id, code: code.trim() }));
+}
+
+function createRoom() {
+    const input = document.getElementById('room-input');
+    const privateInput = document.getElementById('private-input');
+    const timeControl = document.getElementById('time-control-input').value;
+    const rules = document.getElementById('rules-input').value;
+    ws.send(JSON.stringify({ type: 'createRoom', room: input.value.trim(), private: privateInput.checked, timeControl: timeControl, rules: rules }));
+    input.value = '';
+    privateInput.checked = false;
+}
+
+// Show the creator of a private room how to invite their opponent
+function showInvite(room, code) {
+    const link = `${window.location.origin}${window.location.pathname}?room=${encodeURIComponent(room)}&code=${encodeURIComponent(code)}`;
+    const inviteEl = document.getElementById('invite-info');
+    inviteEl.textContent = `Invite code: ${code} - or share ${link}`;
+    inviteEl.classList.remove('hidden');
+}
+
 function addChatMessage(from, name, message) {
     const chatMessages = document.getElementById('chat-messages');
     const msgEl = document.createElement('div');
@@ -429,98 +712,90 @@ function sendChat() {
     }
 }
 
-// Get the current player's unit
-function getMyUnit() {
-    if (myMark === 'X') return gameState.unitX;
-    if (myMark === 'O') return gameState.unitO;
-    return null;
+// Find a unit by the ID the board holds for it
+function getUnit(state, id) {
+    return (state.units || []).find(unit => unit.id === id) || null;
 }
 
-// Get the enemy's unit
-function getEnemyUnit() {
-    if (myMark === 'X') return gameState.unitO;
-    if (myMark === 'O') return gameState.unitX;
-    return null;
-}
+// Symbols for unit classes
+const CLASS_ICONS = { knight: '♞', archer: '➶', scout: '»' };
 
-// Calculate Chebyshev distance (max of dx, dy - allows diagonal movement)
-function getDistance(x1, y1, x2, y2) {
-    const dx = Math.abs(x1 - x2);
-    const dy = Math.abs(y1 - y2);
-    return Math.max(dx, dy);
+// Label for a unit's square: its mark, or its ID when each side has a squad,
+// with its class symbol if it has one
+function unitLabel(state, unit) {
+    const label = state.rules && state.rules.squadSize > 1 ? unit.id : unit.mark;
+    return unit.class ? `${label}${CLASS_ICONS[unit.class] || ''}` : label;
 }
 
-// Check if within movement range (3 squares)
-function isWithinMoveRange(x1, y1, x2, y2) {
-    const dist = getDistance(x1, y1, x2, y2);
-    return dist > 0 && dist <= 3;
+// A side's HP as "hp/max", added up across its squad
+function squadHP(state, mark) {
+    const squad = (state.units || []).filter(unit => unit.mark === mark);
+    const hp = squad.reduce((sum, unit) => sum + unit.hp, 0);
+    const maxHp = squad.reduce((sum, unit) => sum + unit.maxHp, 0);
+    return `${hp}/${maxHp}`;
 }
 
-// Check if within attack range (1 square)
-function isWithinAttackRange(x1, y1, x2, y2) {
-    const dist = getDistance(x1, y1, x2, y2);
-    return dist === 1;
+// What each kind of terrain does, shown when hovering over it
+const TERRAIN_TEXT = {
+    wall: 'Wall - blocks movement and shots',
+    forest: 'Forest - +1 to defend',
+    water: 'Water - costs 2 to move into'
+};
+
+// Colour a cell by its terrain, on boards that have any
+function addTerrain(cell, state, x, y) {
+    const terrain = state.terrain && state.terrain[y][x];
+    if (terrain) {
+        cell.classList.add(terrain);
+        cell.title = TERRAIN_TEXT[terrain] || terrain;
+    }
 }
 
-// Check if a move to (x, y) is valid for the current player
-function isValidMove(x, y) {
-    if (!gameState || gameState.winner) return false;
-    if (gameState.turn !== myMark) return false;
-
-    const unit = getMyUnit();
-    if (!unit) return false;
-
-    // Must be within move range (3 squares)
-    if (!isWithinMoveRange(unit.x, unit.y, x, y)) return false;
-
-    // Must be empty
-    if (gameState.board[y][x] !== '') return false;
+// Size the board's grid to match the game being shown
+function sizeBoard(boardEl, state) {
+    boardEl.style.setProperty('--board-size', state.board.length);
+    return state.board.length;
+}
 
-    // Must be in bounds
-    if (x < 0 || x >= BOARD_SIZE || y < 0 || y >= BOARD_SIZE) return false;
+// Whether (x, y) is in one of the server's hint lists for our selected unit
+function isHinted(list, x, y) {
+    const hints = gameState && gameState.hints;
+    if (!hints || hints.mark !== myMark || !selectedUnit) return false;
+    return hints[list].some(square => square.unit === selectedUnit && square.x === x && square.y === y);
+}
 
-    return true;
+// Check if a move to (x, y) is valid for the selected unit
+function isValidMove(x, y) {
+    return isHinted('moves', x, y);
 }
 
 // Check if attacking at (x, y) is valid
 function isValidAttack(x, y) {
-    if (!gameState || gameState.winner) return false;
-    if (gameState.turn !== myMark) return false;
-
-    const myUnit = getMyUnit();
-    const enemyUnit = getEnemyUnit();
-    if (!myUnit || !enemyUnit) return false;
-
-    // Target must be the enemy position
-    if (enemyUnit.x !== x || enemyUnit.y !== y) return false;
-
-    // Enemy must be within attack range (1 square)
-    if (!isWithinAttackRange(myUnit.x, myUnit.y, x, y)) return false;
-
-    return true;
+    return isHinted('targets', x, y);
 }
 
 function renderBoard() {
     const boardEl = document.getElementById('board');
     boardEl.innerHTML = '';
 
-    const myUnit = getMyUnit();
+    const size = sizeBoard(boardEl, gameState);
 
-    for (let y = 0; y < BOARD_SIZE; y++) {
-        for (let x = 0; x < BOARD_SIZE; x++) {
+    for (let y = 0; y < size; y++) {
+        for (let x = 0; x < size; x++) {
             const cell = document.createElement('div');
             cell.className = 'cell';
             cell.dataset.x = x;
             cell.dataset.y = y;
+            addTerrain(cell, gameState, x, y);
 
-            const value = gameState.board[y][x];
+            const unit = getUnit(gameState, gameState.board[y][x]);
 
-            if (value) {
-                cell.textContent = value;
-                cell.classList.add(value.toLowerCase());
+            if (unit) {
+                cell.textContent = unitLabel(gameState, unit);
+                cell.classList.add(unit.mark.toLowerCase());
 
-                // Highlight if this is the player's unit
-                if (value === myMark) {
+                // Highlight if this is one of the player's units
+                if (unit.mark === myMark) {
                     cell.classList.add('my-unit');
                     // Add turn indicator if it's your turn
                     if (gameState.turn === myMark && !gameState.winner) {
@@ -529,8 +804,7 @@ function renderBoard() {
                 }
 
                 // Add HP bar for units
-                const unit = value === 'X' ? gameState.unitX : gameState.unitO;
-                if (unit && unit.hp > 0) {
+                if (unit.hp > 0) {
                     const hpBar = document.createElement('div');
                     hpBar.className = 'hp-bar';
                     const hpFill = document.createElement('div');
@@ -554,13 +828,13 @@ function renderBoard() {
                 }
             }
 
-            // Highlight selected cell
-            if (selectedCell && selectedCell.x === x && selectedCell.y === y) {
+            // Highlight selected unit
+            if (unit && unit.id === selectedUnit) {
                 cell.classList.add('selected');
             }
 
             // Highlight valid moves/attacks when a unit is selected
-            if (selectedCell && myUnit) {
+            if (selectedUnit) {
                 if (isValidMove(x, y)) {
                     cell.classList.add('valid-move');
                 } else if (isValidAttack(x, y)) {
@@ -591,39 +865,37 @@ function handleCellClick(x, y) {
     if (!gameState || gameState.winner) return;
     if (gameState.turn !== myMark) return;
 
-    const myUnit = getMyUnit();
-    if (!myUnit) return;
-
-    // If clicking on my own unit - select it
-    if (myUnit.x === x && myUnit.y === y) {
-        if (selectedCell && selectedCell.x === x && selectedCell.y === y) {
+    // If clicking on one of my units - select it
+    const unit = getUnit(gameState, gameState.board[y][x]);
+    if (unit && unit.mark === myMark) {
+        if (unit.id === selectedUnit) {
             // Clicking selected unit again - deselect
-            selectedCell = null;
+            selectedUnit = null;
         } else {
             // Select this unit
-            selectedCell = { x, y };
+            selectedUnit = unit.id;
         }
         renderBoard();
         return;
     }
 
     // If no unit selected, do nothing
-    if (!selectedCell) return;
+    if (!selectedUnit) return;
 
     // If clicking on valid move target - move
     if (isValidMove(x, y)) {
-        ws.send(JSON.stringify({ type: 'move', x: x, y: y }));
+        ws.send(JSON.stringify({ type: 'move', unit: selectedUnit, x: x, y: y }));
         return;
     }
 
     // If clicking on valid attack target - attack
     if (isValidAttack(x, y)) {
-        ws.send(JSON.stringify({ type: 'attack', x: x, y: y }));
+        ws.send(JSON.stringify({ type: 'attack', unit: selectedUnit, x: x, y: y }));
         return;
     }
 
     // Clicking elsewhere - deselect
-    selectedCell = null;
+    selectedUnit = null;
     renderBoard();
 }
 
@@ -632,31 +904,354 @@ function updateStatus() {
     const resetBtn = document.getElementById('reset-btn');
 
     // Build HP info with max
-    const xHP = gameState.unitX ? gameState.unitX.hp : 0;
-    const xMaxHP = gameState.unitX ? gameState.unitX.maxHp : 10;
-    const oHP = gameState.unitO ? gameState.unitO.hp : 0;
-    const oMaxHP = gameState.unitO ? gameState.unitO.maxHp : 10;
-    const hpInfo = `X: ${xHP}/${xMaxHP} HP | O: ${oHP}/${oMaxHP} HP`;
+    const hpInfo = `X: ${squadHP(gameState, 'X')} HP | O: ${squadHP(gameState, 'O')} HP`;
+
+    const gameButtons = document.getElementById('game-buttons');
+    const replayBtn = document.getElementById('replay-btn');
 
     if (gameState.winner) {
-        if (gameState.winner === myMark) {
-            statusEl.textContent = `You win! (${hpInfo})`;
+        const reason = RESULT_TEXT[gameState.result] || '';
+        if (gameState.winner === 'draw') {
+            statusEl.textContent = `Draw! (${hpInfo})`;
+        } else if (!isPlayer()) {
+            statusEl.textContent = `${gameState.winner} wins ${reason} (${hpInfo})`;
+        } else if (gameState.winner === myMark) {
+            statusEl.textContent = `You win ${reason}! (${hpInfo})`;
         } else {
-            statusEl.textContent = `You lose! (${hpInfo})`;
+            statusEl.textContent = `You lose ${reason}! (${hpInfo})`;
         }
-        resetBtn.style.display = 'inline-block';
+        resetBtn.textContent = 'Play Again';
+        resetBtn.style.display = isPlayer() ? 'inline-block' : 'none';
+        replayBtn.classList.remove('hidden');
+        gameButtons.classList.add('hidden');
+        hideDrawOffer();
     } else {
-        if (gameState.turn === myMark) {
+        gameButtons.classList.toggle('hidden', !isPlayer());
+        if (gameState.drawOffer) {
+            showDrawOffer(gameState.drawOffer);
+        } else {
+            hideDrawOffer();
+        }
+        if (gameState.draft) {
+            statusEl.textContent = gameState.draft.turn === myMark ? 'Your pick!' : `Waiting for ${gameState.draft.turn} to pick...`;
+        } else if (gameState.turn === myMark) {
             statusEl.textContent = `Your turn! ${hpInfo}`;
         } else {
             statusEl.textContent = `Waiting... ${hpInfo}`;
         }
-        resetBtn.style.display = 'none';
+        replayBtn.classList.add('hidden');
+        resetBtn.textContent = 'Offer Reset';
+        resetBtn.style.display = isPlayer() ? 'inline-block' : 'none';
     }
 }
 
+// How the game ended, keyed by Game.Result
+const RESULT_TEXT = {
+    elimination: 'by elimination',
+    resignation: 'by resignation',
+    timeout: 'on time',
+    abandoned: 'by abandonment'
+};
+
+function showDrawOffer(from) {
+    const offerEl = document.getElementById('draw-offer');
+    const mine = from === myMark;
+    document.getElementById('draw-offer-text').textContent = mine ? 'Draw offered - waiting for a reply...' : `${from} offers a draw.`;
+    document.getElementById('accept-draw-btn').style.display = !mine && isPlayer() ? 'inline-block' : 'none';
+    document.getElementById('decline-draw-btn').style.display = !mine && isPlayer() ? 'inline-block' : 'none';
+    offerEl.classList.remove('hidden');
+}
+
+// Lists recently finished games in the lobby
+function loadReplayList() {
+    fetch('/replays')
+        .then(res => res.json())
+        .then(renderReplayList)
+        .catch(err => console.log('Failed to load replays:', err));
+}
+
+function renderReplayList(games) {
+    const listEl = document.getElementById('replay-list');
+    listEl.innerHTML = '';
+    if (games.length === 0) {
+        listEl.textContent = 'No finished games yet';
+    }
+
+    for (const game of games) {
+        const item = document.createElement('div');
+        item.className = 'room-item';
+
+        const players = game.players.map(p => p.name ? `${p.name} (${p.mark})` : p.mark).join(' vs ');
+        const outcome = game.winner === 'draw' ? 'draw' : `${game.winner} won ${RESULT_TEXT[game.result] || ''}`;
+        const label = document.createElement('span');
+        label.innerHTML = `<strong>${escapeHtml(game.room)}</strong>: ${escapeHtml(players)} <em>(${escapeHtml(outcome)})</em>`;
+        item.appendChild(label);
+
+        const watchBtn = document.createElement('button');
+        watchBtn.textContent = 'Watch';
+        watchBtn.onclick = () => requestReplay(game.id);
+        item.appendChild(watchBtn);
+
+        listEl.appendChild(item);
+    }
+}
+
+function requestReplay(gameId) {
+    ws.send(JSON.stringify({ type: 'replay', gameId }));
+}
+
+// Replay events stream in one at a time, starting at seq 0 and ending with 'end'
+function addReplayEvent(gameId, event) {
+    if (event.seq === 0) {
+        replay = { gameId, events: [], step: 0 };
+    }
+    if (!replay || replay.gameId !== gameId) return;
+
+    replay.events.push(event);
+    if (event.type === 'end') {
+        document.getElementById('replay-overlay').classList.add('active');
+        showReplayStep(0);
+    }
+}
+
+function showReplayStep(step) {
+    replay.step = Math.max(0, Math.min(step, replay.events.length - 1));
+
+    // Board is the latest state at or before this step
+    let state = null;
+    for (let i = replay.step; i >= 0 && !state; i--) {
+        state = replay.events[i].state;
+    }
+    renderReplayBoard(state);
+
+    document.getElementById('replay-text').textContent = describeEvent(replay.events[replay.step]);
+    document.getElementById('replay-step').textContent = `${replay.step + 1} / ${replay.events.length}`;
+    document.getElementById('replay-prev').disabled = replay.step === 0;
+    document.getElementById('replay-next').disabled = replay.step === replay.events.length - 1;
+}
+
+function closeReplay() {
+    replay = null;
+    document.getElementById('replay-overlay').classList.remove('active');
+}
+
+function describeEvent(event) {
+    const at = `(${event.x}, ${event.y})`;
+    switch (event.type) {
+        case 'start':
+            return 'Game start: ' + (event.players || []).map(p => p.name ? `${p.name} (${p.mark})` : p.mark).join(' vs ');
+        case 'move':
+            return `${event.unit || event.mark} moves to ${at}`;
+        case 'pickup':
+            return `${event.unit || event.mark} collects ${event.powerUp.type === 'hp' ? 'an HP' : 'an attack'} boost`;
+        case 'spawn':
+            return `${event.powerUp.type === 'hp' ? 'HP' : 'Attack'} boost appears at ${at}`;
+        case 'attack':
+            return `${event.unit || event.mark} attacks ${at}`;
+        case 'dice':
+            return `Dice rolled in secret: attacker ${event.combat.attackerRoll}, defender ${event.combat.defenderRoll}`;
+        case 'roll':
+            return `${event.mark} reveals a ${event.roll}`;
+        case 'combat':
+            return `${event.combat.loserUnit || event.combat.loserMark} takes ${event.combat.damage} damage`;
+        case 'pick':
+            return `${event.mark} picks a ${event.text}${event.random ? ' (at random)' : ''}`;
+        case 'pass':
+            return `${event.mark} ran out of time - turn passed`;
+        case 'chat':
+            return `${event.name || event.mark}: ${event.text}`;
+        case 'end':
+            return event.mark === 'draw' ? 'Draw!' : `${event.mark} wins ${RESULT_TEXT[event.text] || ''}`;
+    }
+    return event.type;
+}
+
+function renderReplayBoard(state) {
+    const boardEl = document.getElementById('replay-board');
+    boardEl.innerHTML = '';
+    if (!state) return;
+    const size = sizeBoard(boardEl, state);
+
+    for (let y = 0; y < size; y++) {
+        for (let x = 0; x < size; x++) {
+            const cell = document.createElement('div');
+            cell.className = 'cell';
+            addTerrain(cell, state, x, y);
+
+            const unit = getUnit(state, state.board[y][x]);
+            if (unit) {
+                cell.textContent = unitLabel(state, unit);
+                cell.classList.add(unit.mark.toLowerCase());
+
+                if (unit.hp > 0) {
+                    const hpBar = document.createElement('div');
+                    hpBar.className = 'hp-bar';
+                    const hpFill = document.createElement('div');
+                    hpFill.className = 'hp-fill';
+                    const hpPercent = (unit.hp / unit.maxHp) * 100;
+                    if (hpPercent <= 30) {
+                        hpFill.classList.add('low');
+                    }
+                    hpFill.style.width = `${hpPercent}%`;
+                    hpBar.appendChild(hpFill);
+                    cell.appendChild(hpBar);
+                }
+            }
+
+            for (const powerUp of state.powerUps || []) {
+                if (powerUp.x === x && powerUp.y === y) {
+                    const powerUpEl = document.createElement('span');
+                    powerUpEl.className = 'power-up ' + powerUp.type;
+                    powerUpEl.textContent = powerUp.type === 'hp' ? '❤️' : '⚡';
+                    cell.appendChild(powerUpEl);
+                    cell.classList.add('has-power-up');
+                }
+            }
+
+            boardEl.appendChild(cell);
+        }
+    }
+}
+
+// Shows who's next up for a seat, and our own place in line
+function renderQueue(queue, position) {
+    queuePosition = position;
+    const queueEl = document.getElementById('queue-info');
+    if (queue.length === 0) {
+        queueEl.textContent = '';
+    } else {
+        const names = queue.map((entry, i) => `${i + 1}. ${entry.name || 'spectator'}`).join(', ');
+        queueEl.textContent = (position ? `You are #${position} in line. ` : '') + `Next up: ${names}`;
+    }
+    renderQueueButton();
+}
+
+function renderQueueButton() {
+    const queueBtn = document.getElementById('queue-btn');
+    queueBtn.textContent = queuePosition ? 'Leave Queue' : 'Join Queue';
+    queueBtn.style.display = myMark === 'spectator' ? 'inline-block' : 'none';
+}
+
+// Players can call in a bot when nobody else is around (the server says if a seat is free)
+function renderBotControls() {
+    document.getElementById('bot-controls').classList.toggle('hidden', !isPlayer());
+}
+
+function addBot() {
+    const level = document.getElementById('bot-level-input').value;
+    ws.send(JSON.stringify({ type: 'addBot', level: level }));
+}
+
+function hideDrawOffer() {
+    document.getElementById('draw-offer').classList.add('hidden');
+}
+
+function isPlayer() {
+    return myMark === 'X' || myMark === 'O';
+}
+
+// Clock display - the server sends remaining times with each state, and we
+// count down locally between messages
+let clockSnapshot = null; // {clock, turn, receivedAt}
+
+function noteClock(game) {
+    clockSnapshot = game.clock ? { clock: game.clock, turn: game.turn, receivedAt: Date.now() } : null;
+    renderClock();
+}
+
+function formatClock(ms) {
+    const totalSeconds = Math.max(0, Math.ceil(ms / 1000));
+    const minutes = Math.floor(totalSeconds / 60);
+    const seconds = totalSeconds % 60;
+    return `${minutes}:${seconds.toString().padStart(2, '0')}`;
+}
+
+function renderClock() {
+    const clockEl = document.getElementById('clock');
+    if (!clockSnapshot) {
+        clockEl.textContent = '';
+        return;
+    }
+
+    const { clock, turn, receivedAt } = clockSnapshot;
+    const elapsed = clock.running ? Date.now() - receivedAt : 0;
+    const xLeft = clock.remainingX - (turn === 'X' ? elapsed : 0);
+    const oLeft = clock.remainingO - (turn === 'O' ? elapsed : 0);
+    clockEl.textContent = `⏱ X ${formatClock(xLeft)} | O ${formatClock(oLeft)}`;
+}
+
+setInterval(renderClock, 250);
+
+// Draft panel - what each side has picked and what's left in the pool
+function renderDraft() {
+    const draftEl = document.getElementById('draft');
+    const draft = gameState && gameState.draft;
+    draftEl.classList.toggle('hidden', !draft);
+    if (!draft) {
+        pickDeadline = 0;
+        return;
+    }
+
+    const picks = mark => (draft.picks[mark] || []).map(name => CLASS_ICONS[name] + ' ' + name).join(', ') || 'nothing yet';
+    document.getElementById('draft-picks').textContent = `X: ${picks('X')} | O: ${picks('O')}`;
+
+    const poolEl = document.getElementById('draft-pool');
+    poolEl.innerHTML = '';
+    const myPick = draft.turn === myMark && !gameState.winner;
+    for (const name of Object.keys(CLASS_ICONS)) {
+        const left = draft.pool[name] || 0;
+        const btn = document.createElement('button');
+        btn.textContent = `${CLASS_ICONS[name]} ${name} (${left} left)`;
+        btn.disabled = !myPick || left === 0;
+        btn.onclick = () => ws.send(JSON.stringify({ type: 'pick', class: name }));
+        poolEl.appendChild(btn);
+    }
+    const randomBtn = document.createElement('button');
+    randomBtn.textContent = 'Random';
+    randomBtn.disabled = !myPick;
+    randomBtn.onclick = () => ws.send(JSON.stringify({ type: 'pick', class: '' }));
+    poolEl.appendChild(randomBtn);
+    renderPickTimer();
+}
+
+// Pick deadline countdown - the server picks at random when it hits zero
+function renderPickTimer() {
+    const timerEl = document.getElementById('draft-timer');
+    if (!pickDeadline) {
+        timerEl.textContent = '';
+        return;
+    }
+    const seconds = Math.max(0, Math.ceil((pickDeadline - Date.now()) / 1000));
+    timerEl.textContent = `Auto-pick in ${seconds}s`;
+}
+
+setInterval(renderPickTimer, 250);
+
 function resetGame() {
-    ws.send(JSON.stringify({ type: 'reset' }));
+    ws.send(JSON.stringify({ type: 'rematch' }));
+}
+
+// Rematch/reset proposals - the opponent has to agree before the board is wiped
+function showProposal(proposal) {
+    const proposalEl = document.getElementById('proposal');
+    const textEl = document.getElementById('proposal-text');
+    const what = proposal.kind === 'rematch' ? 'a rematch' : 'resetting the game';
+
+    if (proposal.from === myMark) {
+        textEl.textContent = `Waiting for your opponent to accept ${what}...`;
+        document.getElementById('accept-rematch-btn').style.display = 'none';
+        document.getElementById('decline-rematch-btn').style.display = 'none';
+    } else {
+        textEl.textContent = `${proposal.from} proposes ${what}.`;
+        const canAnswer = isPlayer();
+        document.getElementById('accept-rematch-btn').style.display = canAnswer ? 'inline-block' : 'none';
+        document.getElementById('decline-rematch-btn').style.display = canAnswer ? 'inline-block' : 'none';
+    }
+    proposalEl.classList.remove('hidden');
+}
+
+function hideProposal() {
+    document.getElementById('proposal').classList.add('hidden');
 }
 
 function setName() {
@@ -664,16 +1259,57 @@ function setName() {
     const name = input.value.trim();
     if (name) {
         ws.send(JSON.stringify({ type: 'setName', name: name }));
+        // Any other name signs us out
+        if (signedInAs && signedInAs.toLowerCase() !== name.toLowerCase()) {
+            signedInAs = null;
+            document.getElementById('account-info').textContent = '';
+        }
     }
 }
 
+// Show whether we're waiting for matchmaking to find an opponent
+function setSearching(on, text) {
+    searching = on;
+    document.getElementById('find-match-btn').textContent = on ? 'Cancel Search' : 'Find Match';
+    document.getElementById('match-status').textContent = text;
+}
+
+// Sign in to the named account, registering it if it's new. We only know
+// the key for the account we registered (or signed in to) last.
+function signIn() {
+    const name = document.getElementById('name-input').value.trim();
+    if (!name) return;
+    const account = loadAccount();
+    const key = account && account.name.toLowerCase() === name.toLowerCase() ? account.key : '';
+    ws.send(JSON.stringify({ type: 'signIn', name: name, key: key }));
+}
+
 // Set up event listeners and start connection
 document.getElementById('reset-btn').onclick = resetGame;
+document.getElementById('resign-btn').onclick = () => {
+    if (confirm('Resign this game?')) ws.send(JSON.stringify({ type: 'resign' }));
+};
+document.getElementById('draw-btn').onclick = () => ws.send(JSON.stringify({ type: 'offerDraw' }));
+document.getElementById('accept-draw-btn').onclick = () => ws.send(JSON.stringify({ type: 'acceptDraw' }));
+document.getElementById('decline-draw-btn').onclick = () => ws.send(JSON.stringify({ type: 'declineDraw' }));
+document.getElementById('accept-rematch-btn').onclick = () => ws.send(JSON.stringify({ type: 'acceptRematch' }));
+document.getElementById('decline-rematch-btn').onclick = () => ws.send(JSON.stringify({ type: 'declineRematch' }));
+document.getElementById('queue-btn').onclick = () => ws.send(JSON.stringify({ type: queuePosition ? 'leaveQueue' : 'joinQueue' }));
+document.getElementById('add-bot-btn').onclick = addBot;
+document.getElementById('replay-btn').onclick = () => requestReplay(gameState.id);
+document.getElementById('replay-prev').onclick = () => showReplayStep(replay.step - 1);
+document.getElementById('replay-next').onclick = () => showReplayStep(replay.step + 1);
+document.getElementById('replay-close').onclick = closeReplay;
 document.getElementById('chat-send').onclick = sendChat;
 document.getElementById('chat-input').addEventListener('keypress', function(e) {
     if (e.key === 'Enter') sendChat();
 });
 document.getElementById('name-btn').onclick = setName;
+document.getElementById('sign-in-btn').onclick = signIn;
+document.getElementById('find-match-btn').onclick = () => ws.send(JSON.stringify({ type: searching ? 'cancelMatch' : 'findMatch' }));
+document.getElementById('create-room-btn').onclick = createRoom;
+document.getElementById('refresh-rooms-btn').onclick = () => ws.send(JSON.stringify({ type: 'listRooms' }));
+document.getElementById('leave-room-btn').onclick = () => ws.send(JSON.stringify({ type: 'leaveRoom' }));
 document.getElementById('name-input').addEventListener('keypress', function(e) {
     if (e.key === 'Enter') setName();
 });
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+// Store persists room snapshots so games survive a server restart.
+// Snapshots are opaque blobs keyed by room ID.
+type Store interface {
+	Save(id string, data []byte) error   // Write (or overwrite) a room's snapshot
+	Delete(id string) error              // Forget a room that closed
+	LoadAll() (map[string][]byte, error) // Every saved snapshot, by room ID
+}
+
+// store is where rooms snapshot themselves (nil = persistence disabled)
+var store Store
+
+// SnapshotInterval is how often a room re-saves between actions, to catch
+// clocks and timers that change the state on their own
+const SnapshotInterval = 5 * time.Second
+
+// FileStore keeps one JSON file per room in a directory
+type FileStore struct {
+	Dir string
+}
+
+// newFileStore creates the directory if needed
+func newFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// path returns the file for a room. IDs are user-chosen, so they're hex
+// encoded rather than trusted as file names.
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.Dir, hex.EncodeToString([]byte(id))+".json")
+}
+
+// Save writes to a temp file and renames it, so a crash mid-write never
+// leaves a truncated snapshot behind
+func (s *FileStore) Save(id string, data []byte) error {
+	tmp := s.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(id))
+}
+
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) LoadAll() (map[string][]byte, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string][]byte)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		id, err := hex.DecodeString(name)
+		if err != nil {
+			continue // Not one of ours
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots[string(id)] = data
+	}
+	return snapshots, nil
+}
+
+// RoomSnapshot is everything needed to put a room back the way it was.
+// Connections and spectators aren't saved - players reconnect with their
+// session token and get their seat back.
+type RoomSnapshot struct {
+	ID          string           `json:"id"`
+	Private     bool             `json:"private,omitempty"`
+	InviteCode  string           `json:"inviteCode,omitempty"`
+	TimeControl string           `json:"timeControl"`
+	Rules       string           `json:"rules"` // Preset for the room's next games
+	Game        *Game            `json:"game"`
+	Combat      *gridwars.Combat `json:"combat,omitempty"`     // Pending combat, if dice were still to be rolled
+	Commitment  string           `json:"commitment,omitempty"` // Published hash of the pending combat's dice
+	Salt        string           `json:"salt,omitempty"`       // Salt behind the commitment
+	Seats       []SeatSnapshot   `json:"seats"`
+	Seed        uint64           `json:"seed"`
+	RNG         []byte           `json:"rng"` // Random source state, to carry on where it left off
+}
+
+// SeatSnapshot is a seat without its connection
+type SeatSnapshot struct {
+	Token string `json:"token"`
+	Mark  string `json:"mark"`
+	Name  string `json:"name,omitempty"`
+	Bot   string `json:"bot,omitempty"` // Difficulty, if the seat is a server-run bot
+}
+
+// snapshot captures the room's state. Only called from the room's goroutine.
+func (r *Room) snapshot() *RoomSnapshot {
+	snap := &RoomSnapshot{
+		ID:          r.ID,
+		Private:     r.private,
+		InviteCode:  r.inviteCode,
+		TimeControl: r.timeControl.String(),
+		Rules:       r.rules.Name,
+		Game:        r.game,
+		Seats:       []SeatSnapshot{},
+	}
+	if r.pendingCombat != nil {
+		snap.Combat = r.game.Combat
+		snap.Commitment, snap.Salt = r.pendingCombat.Commitment, r.pendingCombat.Salt
+	}
+	snap.Seed = r.seed
+	snap.RNG, _ = r.pcg.MarshalBinary()
+	for _, mark := range []string{"X", "O"} {
+		if seat := r.seatFor(mark); seat != nil {
+			s := SeatSnapshot{Token: seat.Token, Mark: seat.Mark, Name: seat.Name}
+			if seat.isBot() {
+				s.Bot = seat.Client.bot.Level
+			}
+			snap.Seats = append(snap.Seats, s)
+		}
+	}
+	return snap
+}
+
+// saveSnapshot writes the room to the store if anything changed since the
+// last save. Only called from the room's goroutine.
+func (r *Room) saveSnapshot(now time.Time) {
+	if store == nil {
+		return
+	}
+	r.savedAt = now
+
+	data, err := json.Marshal(r.snapshot())
+	if err != nil {
+		fmt.Println("Snapshot error:", err)
+		return
+	}
+	if string(data) == string(r.saved) {
+		return
+	}
+	if err := store.Save(r.ID, data); err != nil {
+		fmt.Println("Snapshot error:", err)
+		return
+	}
+	r.saved = data
+}
+
+// deleteSnapshot forgets a room that has shut down
+func (r *Room) deleteSnapshot() {
+	if store == nil {
+		return
+	}
+	if err := store.Delete(r.ID); err != nil {
+		fmt.Println("Snapshot error:", err)
+	}
+}
+
+// restoreRoom rebuilds a room from a snapshot. Every seat comes back held,
+// waiting for its player to reconnect (for savedSeatGracePeriod, since
+// nobody's connected yet). The room's goroutine isn't started.
+func restoreRoom(data []byte, now time.Time) (*Room, error) {
+	var snap RoomSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	if snap.Game == nil {
+		return nil, errors.New("snapshot has no game")
+	}
+	tc, err := parseTimeControl(snap.TimeControl)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := parseRules(snap.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRoom(snap.ID, RoomOptions{Private: snap.Private, Password: snap.InviteCode, TimeControl: tc, Rules: rules})
+	r.game = snap.Game
+	if r.game.Clock != nil {
+		r.game.Clock.tc = tc
+	}
+	r.seedGame(snap.Seed)
+	if snap.RNG != nil {
+		if err := r.pcg.UnmarshalBinary(snap.RNG); err != nil {
+			return nil, err
+		}
+	}
+	r.resumeLog()
+
+	for _, s := range snap.Seats {
+		if s.Bot != "" {
+			// Bots don't need to reconnect - they're back straight away
+			bot, err := newBotClient(s.Bot)
+			if err != nil {
+				return nil, err
+			}
+			bot.Role = s.Mark
+			r.clients[bot] = true
+			r.seats[s.Mark] = &Seat{Token: s.Token, Mark: s.Mark, Name: s.Name, Client: bot}
+			r.tokens[s.Token] = true
+			continue
+		}
+		r.seats[s.Mark] = &Seat{Token: s.Token, Mark: s.Mark, Name: s.Name, HeldUntil: now.Add(seatGracePeriod)}
+		r.held[s.Token] = true
+		r.tokens[s.Token] = true
+	}
+
+	// The combat's dice aren't part of the game's JSON, so put them back
+	if snap.Combat != nil {
+		r.game.Combat = snap.Combat
+		r.pendingCombat = &PendingCombat{Commitment: snap.Commitment, Salt: snap.Salt}
+		r.pendingCombat.startRollTimer(now)
+	}
+	r.updateHolds(now)
+
+	r.saved = data
+	r.publishInfo()
+	return r, nil
+}
+
+// restoreRooms brings back every room saved in the store. Rooms with nobody
+// to come back for are dropped. Call before openDefaultRoom.
+func restoreRooms() {
+	if store == nil {
+		return
+	}
+	snapshots, err := store.LoadAll()
+	if err != nil {
+		fmt.Println("Error loading snapshots:", err)
+		return
+	}
+
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	now := time.Now()
+	for id, data := range snapshots {
+		room, err := restoreRoom(data, now)
+		if err == nil && room.ID != id {
+			err = errors.New("room ID doesn't match")
+		}
+		if err != nil {
+			fmt.Printf("Ignoring bad snapshot for room %q: %v\n", id, err)
+			store.Delete(id)
+			continue
+		}
+		if len(room.held) == 0 && id != DefaultRoomID {
+			store.Delete(id)
+			continue
+		}
+		registerRoom(room)
+		fmt.Printf("Restored room %q (%d seats held)\n", id, len(room.held))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-multiplayer/gridwars"
+)
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	fs, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Save("../odd/id", []byte(`{"id":"../odd/id"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Save("main", []byte(`{"id":"main"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Delete("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Delete("never-saved"); err != nil {
+		t.Errorf("deleting a missing snapshot should not fail: %v", err)
+	}
+
+	snapshots, err := fs.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || string(snapshots["../odd/id"]) != `{"id":"../odd/id"}` {
+		t.Errorf("unexpected snapshots: %v", snapshots)
+	}
+}
+
+func TestRestoreRoom_HoldsSeatsAndCombat(t *testing.T) {
+	tc, _ := parseTimeControl("clock:300+5")
+	r := newRoom("restore-test", RoomOptions{Private: true, Password: "secret", TimeControl: tc})
+	startTestCombat(r)
+	r.game.Combat.AttackerRolled = true
+	r.pendingCombat.commit(r.game.Combat)
+	seatX := r.seatFor("X")
+	seatX.Name = "alice"
+	r.game.Turns = 3
+	r.game.Unit("O1").HP = 4
+	r.game.Clock.RemainingX = 1234
+
+	data, err := json.Marshal(r.snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := restoreRoom(data, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.private || restored.inviteCode != "secret" || restored.timeControl != tc {
+		t.Error("room options not restored")
+	}
+	if restored.game.Turns != 3 || restored.game.Unit("O1").HP != 4 {
+		t.Error("game state not restored")
+	}
+	if restored.game.Clock.RemainingX != 1234 || !restored.game.Clock.forfeitsOnExpiry() {
+		t.Error("clock not restored")
+	}
+
+	seat := restored.seatFor("X")
+	if seat == nil || seat.Token != seatX.Token || seat.Name != "alice" || seat.Client != nil {
+		t.Fatalf("expected X's seat held for them, got %+v", seat)
+	}
+	if !restored.held[seatX.Token] || restored.seatFor("O") == nil {
+		t.Error("expected both seats held")
+	}
+
+	combat := restored.game.Combat
+	if combat == nil || restored.pendingCombat == nil || combat.AttackerRoll != 5 || !combat.AttackerRolled {
+		t.Fatal("pending combat not restored")
+	}
+	if restored.pendingCombat.Commitment != r.pendingCombat.Commitment || restored.pendingCombat.Salt != r.pendingCombat.Salt {
+		t.Error("combat's commitment not restored")
+	}
+}
+
+func TestRestoreRoom_KeepsRules(t *testing.T) {
+	r := newRoom("rules-test", RoomOptions{Rules: gridwars.BigBoard})
+	r.takeSeat(&Client{}, "X")
+	r.takeSeat(&Client{}, "O")
+
+	data, err := json.Marshal(r.snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := restoreRoom(data, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.game.Rules != gridwars.BigBoard || len(restored.game.Board) != gridwars.BigBoard.BoardSize {
+		t.Errorf("expected the game to keep big board rules, got %+v", restored.game.Rules)
+	}
+	restored.resetGame()
+	if restored.game.Rules != gridwars.BigBoard || restored.Info().Rules != "bigboard" {
+		t.Error("expected the room's next game to use its rules")
+	}
+}
+
+func TestRestoreRoom_RejectsSnapshotWithoutRules(t *testing.T) {
+	snap := newRoom("no-rules-test", RoomOptions{}).snapshot()
+	snap.Rules = ""
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreRoom(data, time.Now()); err != ErrRules {
+		t.Errorf("expected ErrRules, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -15,9 +17,51 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// sendJSON sends a JSON message to a client
-func sendJSON(conn *websocket.Conn, msg ServerMessage) {
-	conn.WriteJSON(msg)
+// sendJSON sends a JSON message to a client (clients without a connection are skipped)
+func sendJSON(client *Client, msg ServerMessage) {
+	if client.Conn == nil {
+		return
+	}
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+	client.Conn.WriteJSON(msg)
+}
+
+// A connection that goes pongWait without answering a ping is taken for
+// dead. Pings go out every pingPeriod, comfortably inside that.
+var (
+	pongWait   = 60 * time.Second
+	pingPeriod = 50 * time.Second
+)
+
+// keepAlive pings the connection until stop is closed and makes reads fail
+// once it stops answering, so a connection that died without closing (a
+// dropped Wi-Fi link, a sleeping laptop) is noticed and its seat held.
+// Only call from the connection's reader goroutine.
+func keepAlive(conn *websocket.Conn) (stop chan struct{}) {
+	wait, period := pongWait, pingPeriod
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Safe alongside sendJSON's writes, unlike WriteJSON
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(period)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return stop
 }
 
 // handleWebSocket handles new WebSocket connections
@@ -29,16 +73,34 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	// Create client and tell game manager they joined
-	client := &Client{Conn: conn}
-	actions <- Action{Type: ActionJoin, Client: client}
+	serveClient(&Client{Conn: conn}, r.URL.Query())
+}
+
+// serveClient routes a newly connected client into the requested room
+// (?room=<id>&code=<invite>&token=<session>), or starts them in the lobby,
+// then handles their messages until they disconnect
+func serveClient(client *Client, query url.Values) {
+	conn := client.Conn
+	defer close(keepAlive(conn))
+
+	if roomID := query.Get("room"); roomID != "" {
+		code, token := query.Get("code"), query.Get("token")
+		switchRoom(client, func() (*Room, error) { return openRoom(roomID, code, token) }, token)
+	}
+	if client.room == nil {
+		sendJSON(client, ServerMessage{Type: "rooms", Rooms: listRooms()})
+	}
 
 	// Read messages and forward to game manager
 	for {
 		_, messageBytes, err := conn.ReadMessage()
 		if err != nil {
 			fmt.Println("Client disconnected:", client.Role)
-			actions <- Action{Type: ActionLeave, Client: client}
+			matchmaker.Remove(client)
+			adoptMatch(client, true)
+			if client.room != nil {
+				exitRoom(client.room, client, ActionDisconnect)
+			}
 			break
 		}
 
@@ -49,22 +111,46 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		fmt.Printf("Received from %s: %+v\n", client.Role, msg)
+		// Only the type - messages can carry account keys, tokens and invite codes
+		fmt.Printf("Received from %s: %s\n", client.Role, msg.Type)
+
+		// Matchmaking may have found them a room since their last message
+		adoptMatch(client, false)
+
+		// Lobby messages are handled right here
+		if handleLobbyMessage(client, msg) {
+			continue
+		}
+
+		// Everything else needs a room
+		if client.room == nil {
+			sendJSON(client, ServerMessage{Type: "error", Error: "Join a room first"})
+			continue
+		}
 
-		// Forward to game manager via channel
+		// Forward to the room's game manager via channel
+		actions := client.room.actions
 		switch ActionType(msg.Type) {
 		case ActionMove:
-			actions <- Action{Type: ActionMove, Client: client, X: msg.X, Y: msg.Y}
+			actions <- Action{Type: ActionMove, Client: client, Unit: msg.Unit, X: msg.X, Y: msg.Y}
 		case ActionAttack:
-			actions <- Action{Type: ActionAttack, Client: client, X: msg.X, Y: msg.Y}
+			actions <- Action{Type: ActionAttack, Client: client, Unit: msg.Unit, X: msg.X, Y: msg.Y}
 		case ActionRoll:
 			actions <- Action{Type: ActionRoll, Client: client}
-		case ActionReset:
-			actions <- Action{Type: ActionReset, Client: client}
+		case ActionPick:
+			actions <- Action{Type: ActionPick, Client: client, Class: msg.Class}
+		case ActionReset, ActionRematch, ActionAcceptRematch, ActionDeclineRematch,
+			ActionResign, ActionOfferDraw, ActionAcceptDraw, ActionDeclineDraw,
+			ActionJoinQueue, ActionLeaveQueue:
+			actions <- Action{Type: ActionType(msg.Type), Client: client}
 		case ActionChat:
 			actions <- Action{Type: ActionChat, Client: client, Text: msg.Message}
 		case ActionSetName:
 			actions <- Action{Type: ActionSetName, Client: client, Name: msg.Name}
+		case ActionAddBot:
+			actions <- Action{Type: ActionAddBot, Client: client, Level: msg.Level}
+		case ActionSignIn:
+			actions <- Action{Type: ActionSignIn, Client: client, Name: msg.Name, Key: msg.Key}
 		}
 	}
 }